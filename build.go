@@ -1,67 +1,695 @@
 package poetryrun
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/paketo-buildpacks/libreload-packit"
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
 // Build will return a packit.BuildFunc that will be invoked during the build
-// phase of the buildpack lifecycle.
+// phase of the buildpack lifecycle. It reads its configuration from the
+// "NAME=value" variables listed by environ, e.g. os.Environ.
 //
 // Build assigns the image a launch process of 'poetry run <target>' where <target>
-// is the key of a poetry script or system executable. This can be set via `BP_POETRY_RUN_TARGET`
-// or inferred from pyproject.toml when there is exactly one script.
-func Build(pyProjectParser PyProjectParser, logger scribe.Emitter, reloader Reloader) packit.BuildFunc {
+// is the key of a poetry script or system executable. Any arguments that
+// follow the script or executable are assigned as default args, which can be
+// overridden when the image is run. This can be set via `BP_POETRY_RUN_TARGET`
+// or [tool.paketo.poetry-run] in pyproject.toml, or inferred by the Resolver.
+//
+// When the run target is the script picked by the preferred script names,
+// the other scripts are assigned non-default process types derived from their
+// keys, or mapped under [tool.paketo.poetry-run.process-types].
+//
+// In non-package mode (package-mode = false), Poetry does not install the
+// scripts of the project, so a script target is run as 'python -m <module>'
+// or 'python -c <code>' instead.
+//
+// Run profiles declared with `BP_POETRY_RUN_TARGET_<PROFILE>` or under
+// [tool.paketo.poetry-run.profiles] in pyproject.toml are baked into a launch
+// layer, alongside an exec.d helper that selects the profile named by
+// `BPL_POETRY_RUN_PROFILE` when the image is run.
+//
+// When live reload is enabled, the reloadable process is wrapped by the given
+// Reloader, with the graceful restart settings of `BP_LIVE_RELOAD_*`, or,
+// when `BP_LIVE_RELOAD_STRATEGY=framework-native`, runs the target with the
+// flags that enable the reloader of its dev server.
+//
+// With `BP_LIVE_RELOAD_SYNC_DEPENDENCIES=true`, the reloadable process also
+// runs 'poetry install --sync' and restarts when pyproject.toml or
+// poetry.lock change.
+//
+// When `BP_POETRY_RUN_DOTENV_ENABLED` is true, Build adds an exec.d helper
+// that loads the .env files named by `BP_POETRY_RUN_DOTENV_FILES` into the
+// environment of the processes at launch.
+//
+// Build checks that poetry.lock matches the content hash of pyproject.toml
+// and that its lock-version can be read by the Poetry version in the build
+// plan. Problems are warnings, or fail the build with
+// `BP_POETRY_RUN_LOCK_CHECK=fail`.
+//
+// Settings in poetry.toml that conflict with the venv built by the
+// poetry-install buildpack are reported as warnings and, where possible,
+// overridden through the launch environment.
+//
+// When `BP_DEBUG_ENABLED` is true, Build also assigns a 'debug' process that
+// runs the same target under debugpy.
+//
+// A non-default 'health' process can be configured with a poetry run target,
+// or with an HTTP path or TCP port that is checked by a small binary installed
+// into a launch layer.
+//
+// Auxiliary, non-default processes (e.g. 'release') can be declared with
+// `BP_POETRY_RUN_PROCESS_<NAME>` or under [tool.paketo.poetry-run.processes]
+// in pyproject.toml. They are also launched through 'poetry run'.
+func Build(pyProjectParser PyProjectParser, lockFileParser LockFileParser, configFileParser ConfigFileParser, logger scribe.Emitter, reloader Reloader, environ func() []string) packit.BuildFunc {
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
 
-		args := []string{"run"}
+		resolver := NewResolver(environ, os.DirFS(context.WorkingDir))
+		pyProject, err := parsePyProject(resolver, context.WorkingDir, pyProjectParser)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
 		logger.Debug.Process("Finding the poetry run target")
-		if runTarget, ok := os.LookupEnv("BP_POETRY_RUN_TARGET"); ok {
-			args = append(args, strings.Split(runTarget, " ")...)
-			logger.Debug.Subprocess("Found BP_POETRY_RUN_TARGET=%s", runTarget)
-		} else {
-			scriptKey, err := pyProjectParser.Parse(filepath.Join(context.WorkingDir, "pyproject.toml"))
+		target, err := resolver.Resolve(pyProject)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		logger.Debug.Subprocess(target.Explanation)
+
+		var preferredScript string
+		if target.Source == PreferredScriptSource {
+			preferredScript = target.Argv[0]
+			logger.Process("Selected script %s from the preferred scripts", preferredScript)
+			logger.Subprocess("The other scripts are assigned non-default process types")
+			logger.Break()
+		}
+
+		if pyProject.NonPackageMode {
+			logger.Process("Found package-mode = false in pyproject.toml")
+
+			translated, err := nonPackageTarget(target, pyProject.Scripts)
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
-			args = append(args, scriptKey)
-			logger.Debug.Subprocess("Found pyproject.toml script=%s", scriptKey)
+
+			if translated.Kind != target.Kind {
+				logger.Subprocess("Poetry does not install script %s, running python %s %s instead", target.Argv[0], translated.Argv[1], shellQuote(translated.Argv[2]))
+			} else {
+				logger.Subprocess("Running poetry run %s as-is", strings.Join(target.Argv, " "))
+			}
+			logger.Break()
+
+			target = translated
 		}
 
-		originalProcess := packit.Process{
-			Type:    "web",
-			Command: "poetry",
-			Args:    args,
-			Default: true,
-			Direct:  true,
+		var warnings []string
+
+		lockCheck, err := resolver.lockCheckMode()
+		if err != nil {
+			return packit.BuildResult{}, err
 		}
 
-		processes := make([]packit.Process, 0)
+		if lockCheck != OffLockCheck {
+			poetryLock, err := lockFileParser.Parse(filepath.Join(context.WorkingDir, "poetry.lock"))
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			problems := lockFileProblems(pyProject, poetryLock, resolver.poetryVersion())
+			if len(problems) > 0 && lockCheck == FailLockCheck {
+				return packit.BuildResult{}, fmt.Errorf("failed the poetry.lock check: %s: set BP_POETRY_RUN_LOCK_CHECK=warn to build anyway", strings.Join(problems, "; "))
+			}
+			warnings = append(warnings, problems...)
+		}
 
-		if shouldEnableReload, err := reloader.ShouldEnableLiveReload(); err != nil {
+		options, err := resolver.poetryOptions(pyProject.PoetryRun.PoetryOptions)
+		if err != nil {
 			return packit.BuildResult{}, err
-		} else if shouldEnableReload {
-			nonReloadableProcess, reloadableProcess := reloader.TransformReloadableProcesses(originalProcess, libreload.ReloadableProcessSpec{
-				WatchPaths: []string{context.WorkingDir},
-			})
+		}
+
+		expandEnabled, err := resolver.lookupBoolEnv("BP_POETRY_RUN_EXPAND_ENV")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		profiles, profileSources, err := resolver.profileTargets(pyProject.PoetryRun.Profiles)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if expandEnabled {
+			profiles, err = expandTargets(profiles, "profile", resolver.lookupEnv)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		if len(profiles) > 0 && target.Kind == CallableTarget {
+			return packit.BuildResult{}, errors.New("run profiles do not support a script that runs with python -c in non-package mode: set BP_POETRY_RUN_TARGET to a command that runs it, e.g. 'python -m <module>'")
+		}
+
+		originalProcess := poetryRunProcess("web", target, true)
+
+		var layers []packit.Layer
+		if len(profiles) > 0 {
+			layer, err := context.Layers.Get(ProfilesLayerName)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer, err = layer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer.Launch = true
+			layer.LaunchEnv.Default("POETRY_RUN_TARGET", strings.Join(target.Argv, " "))
+			for name, profileTarget := range profiles {
+				layer.LaunchEnv.Default(profileEnvVar(name), profileTarget)
+			}
+			layer.ExecD = []string{filepath.Join(context.CNBPath, "bin", "select-profile")}
+
+			logger.Process("Configuring run profiles")
+			poetryRun := strings.Join(poetryCommand(options), " ")
+			logger.Subprocess("%s: %s %s", defaultProfile, poetryRun, strings.Join(target.Argv, " "))
+			for _, name := range profileNames(profiles) {
+				logger.Subprocess("%s: %s %s", name, poetryRun, profiles[name])
+			}
+			logger.Action("Select a profile at launch with BPL_POETRY_RUN_PROFILE")
+			logger.Break()
+
+			originalProcess = profileProcess("web")
+			layers = append(layers, layer)
+		}
+
+		processes := make([]packit.DirectProcess, 0)
+		sources := map[string]TargetSource{originalProcess.Type: target.Source}
+		reload := ReportReload{WatchPaths: []string{}}
+
+		if dotenvEnabled, err := resolver.lookupBoolEnv("BP_POETRY_RUN_DOTENV_ENABLED"); err != nil {
+			return packit.BuildResult{}, err
+		} else if dotenvEnabled {
+			layer, err := context.Layers.Get(DotenvLayerName)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer, err = layer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			files := resolver.dotenvFiles(context.WorkingDir)
+
+			layer.Launch = true
+			layer.LaunchEnv.Default("POETRY_RUN_DOTENV_FILES", strings.Join(files, string(filepath.ListSeparator)))
+			layer.ExecD = []string{filepath.Join(context.CNBPath, "bin", "load-dotenv")}
+
+			logger.Process("Configuring .env files")
+			for _, file := range files {
+				logger.Subprocess("Loading %s at launch", file)
+			}
+			logger.Action("Variables that are already set are never overwritten")
+			logger.Break()
+
+			layers = append(layers, layer)
+		}
+
+		runtime := resolver.buildRuntime(context.WorkingDir, pyProject.Name)
+		if runtime.Venv != "" {
+			logger.Process("Recording the Python runtime")
+			logger.Subprocess("Venv %s created with CPython %s", runtime.Venv, runtime.CPythonVersion)
+			logger.Action("Recorded in the image labels %s and %s", CPythonVersionLabel, VenvPythonVersionLabel)
+			logger.Break()
+		}
+
+		if preflightEnabled, err := resolver.lookupBoolEnv("BP_POETRY_RUN_PREFLIGHT"); err != nil {
+			return packit.BuildResult{}, err
+		} else if preflightEnabled {
+			layer, err := context.Layers.Get(PreflightLayerName)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer, err = layer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer.Launch = true
+			if pyProject.Name != "" {
+				layer.LaunchEnv.Default("POETRY_RUN_PREFLIGHT_PROJECT", pyProject.Name)
+			}
+			layer.ExecD = []string{filepath.Join(context.CNBPath, "bin", "preflight")}
+
+			logger.Process("Configuring the launch preflight check")
+			logger.Subprocess("Checking that the venv exists and matches the CPython layer")
+			if runtime.VenvPythonVersion != "" {
+				layer.LaunchEnv.Default("POETRY_RUN_PREFLIGHT_PYTHON_VERSION", runtime.VenvPythonVersion)
+				logger.Subprocess("Checking that the venv still uses Python %s", runtime.VenvPythonVersion)
+			}
+			if target.Kind == ScriptTarget {
+				layer.LaunchEnv.Default("POETRY_RUN_PREFLIGHT_SCRIPT", target.Argv[0])
+				logger.Subprocess("Checking that script %s is installed", target.Argv[0])
+			}
+			logger.Action("Disable it at launch with BPL_POETRY_RUN_PREFLIGHT=false")
+			logger.Break()
+
+			layers = append(layers, layer)
+		}
+
+		poetryConfig, err := configFileParser.Parse(filepath.Join(context.WorkingDir, "poetry.toml"))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		launchEnv := packit.Environment{}
+		for _, conflict := range poetryConfig.Conflicts() {
+			warnings = append(warnings, conflict.Warning)
+			if conflict.Env != "" {
+				launchEnv.Override(conflict.Env, conflict.Value)
+			}
+		}
+
+		if len(launchEnv) > 0 {
+			layer, err := context.Layers.Get(PoetryConfigLayerName)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer, err = layer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer.Launch = true
+			layer.LaunchEnv = launchEnv
+
+			logger.EnvironmentVariables(layer)
+
+			layers = append(layers, layer)
+		}
+
+		strategy, shouldEnableReload, err := resolver.liveReload(reloader)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		reload.Strategy = strategy
+
+		syncDependencies, err := resolver.lookupBoolEnv("BP_LIVE_RELOAD_SYNC_DEPENDENCIES")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		var spec ReloadSpec
+		var syncBinary string
+		if shouldEnableReload {
+			reload.WatchPaths = []string{context.WorkingDir}
+
+			spec, err = resolver.reloadSpec(reload.WatchPaths)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if syncDependencies {
+				reload.SyncPaths = syncPaths(poetryProjectDir(context.WorkingDir, options))
+
+				var layer packit.Layer
+				layer, syncBinary, err = installHelper(context, SyncLayerName, "sync-dependencies")
+				if err != nil {
+					return packit.BuildResult{}, fmt.Errorf("failed to install the dependency sync: %w", err)
+				}
+				layers = append(layers, layer)
+
+				logger.Process("Configuring dependency sync")
+				logger.Subprocess("Running %s when %s change", strings.Join(poetryInstallCommand(options), " "), strings.Join(reload.SyncPaths, " or "))
+				logger.Action("The running process is kept when the install fails")
+				logger.Break()
+			}
+		} else if syncDependencies {
+			warnings = append(warnings, "BP_LIVE_RELOAD_SYNC_DEPENDENCIES only applies when live reload is enabled and is ignored")
+		}
+
+		switch {
+		case shouldEnableReload && strategy == FrameworkNativeStrategy:
+			if len(profiles) > 0 {
+				return packit.BuildResult{}, fmt.Errorf("BP_LIVE_RELOAD_STRATEGY=framework-native does not support run profiles: set BP_LIVE_RELOAD_STRATEGY=watchexec")
+			}
+
+			reload.Enabled = true
+
+			if spec.isGraceful() {
+				warnings = append(warnings, "BP_LIVE_RELOAD_STOP_SIGNAL, BP_LIVE_RELOAD_STOP_TIMEOUT, BP_LIVE_RELOAD_DEBOUNCE, BP_LIVE_RELOAD_ON_CHANGE, BP_LIVE_RELOAD_SIGNAL and BP_LIVE_RELOAD_CLEAR_SCREEN only apply to BP_LIVE_RELOAD_STRATEGY=watchexec and are ignored")
+			}
+
+			reloadCommand, reloadArgs, err := frameworkReloadCommand(target, reload.WatchPaths)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			reloadableProcess := packit.DirectProcess{
+				Type:    "reload-" + originalProcess.Type,
+				Command: append([]string{"poetry", "run"}, reloadCommand...),
+				Args:    reloadArgs,
+				Default: true,
+			}
+			if syncBinary != "" {
+				reloadableProcess = syncDependenciesProcess(syncBinary, reloadableProcess, reload.SyncPaths, options, ReloadSpec{})
+			}
+			nonReloadableProcess := originalProcess
+			nonReloadableProcess.Default = false
+
+			processes = append(processes, reloadableProcess, nonReloadableProcess)
+			sources[reloadableProcess.Type] = EnvironmentSource
+
+			logger.Debug.Process("Enabling the framework's live reload")
+			logger.Debug.Subprocess("Running poetry run %s", strings.Join(append(reloadCommand, reloadArgs...), " "))
+
+		case shouldEnableReload:
+			reload.Enabled = true
+
+			process := originalProcess
+			if syncBinary != "" {
+				process = syncDependenciesProcess(syncBinary, originalProcess, reload.SyncPaths, options, spec)
+				spec.IgnorePaths = append(spec.IgnorePaths, reload.SyncPaths...)
+			}
+
+			_, reloadableProcess := reloadableProcesses(reloader, process, spec)
+			nonReloadableProcess := originalProcess
+			nonReloadableProcess.Default = false
 			processes = append(processes, reloadableProcess, nonReloadableProcess)
-		} else {
+			sources[reloadableProcess.Type] = EnvironmentSource
+
+		default:
 			processes = append(processes, originalProcess)
 		}
 
-		logger.LaunchProcesses(processes)
+		if debugEnabled, err := resolver.lookupBoolEnv("BP_DEBUG_ENABLED"); err != nil {
+			return packit.BuildResult{}, err
+		} else if debugEnabled {
+			logger.Debug.Process("Configuring the debug process")
+
+			waitForClient, err := resolver.lookupBoolEnv("BP_DEBUG_WAIT_FOR_CLIENT")
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			debug, err := debugProcess(target, pyProject.Scripts, waitForClient)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("BP_DEBUG_ENABLED is set but no debug process is added: %s", err))
+			} else {
+				processes = append(processes, debug)
+				sources[debug.Type] = EnvironmentSource
+				logger.Debug.Subprocess("Listening for debugpy clients on BPL_DEBUG_PORT (default %s)", debugPort)
+			}
+		}
+
+		health, healthSource, err := resolver.healthConfig(pyProject.PoetryRun.Health)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if expandEnabled && health.Target != "" {
+			health.Target, err = expandEnv(health.Target, resolver.lookupEnv)
+			if err != nil {
+				return packit.BuildResult{}, fmt.Errorf("failed to expand the health target: %w", err)
+			}
+		}
+
+		switch {
+		case health.Target != "":
+			sources["health"] = healthSource
+			target, err := processTarget(strings.Fields(health.Target), pyProject)
+			if err != nil {
+				return packit.BuildResult{}, fmt.Errorf("invalid health target: %w", err)
+			}
+			processes = append(processes, poetryRunProcess("health", target, false))
+
+			logger.Debug.Process("Configuring the health process")
+			logger.Debug.Subprocess("Running poetry run %s", health.Target)
+
+		case health.HTTPPath != "" || health.TCPPort != 0:
+			sources["health"] = healthSource
+
+			layer, binary, err := installHelper(context, HealthLayerName, "health")
+			if err != nil {
+				return packit.BuildResult{}, fmt.Errorf("failed to install the health check: %w", err)
+			}
+
+			processes = append(processes, packit.DirectProcess{
+				Type:    "health",
+				Command: append([]string{binary}, health.checkArgs()...),
+			})
+
+			logger.Debug.Process("Configuring the health process")
+			if health.HTTPPath != "" {
+				logger.Debug.Subprocess("Checking HTTP GET %s on $PORT", health.HTTPPath)
+			} else {
+				logger.Debug.Subprocess("Checking TCP port %d", health.TCPPort)
+			}
+
+			layers = append(layers, layer)
+		}
+
+		auxiliaryConfig, auxiliarySources := resolver.auxiliaryTargets(pyProject.PoetryRun.Processes)
+		if expandEnabled {
+			auxiliaryConfig, err = expandTargets(auxiliaryConfig, "process", resolver.lookupEnv)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		auxiliary, err := auxiliaryProcesses(auxiliaryConfig, pyProject, processes)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if len(auxiliary) > 0 {
+			logger.Debug.Process("Found auxiliary processes")
+			for _, process := range auxiliary {
+				logger.Debug.Subprocess("%s: %s", process.Type, strings.Join(append(process.Command, process.Args...), " "))
+			}
+			processes = append(processes, auxiliary...)
+		}
+
+		if preferredScript != "" {
+			scripts, skipped, err := scriptProcesses(pyProject, preferredScript, processes)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			for _, process := range scripts {
+				sources[process.Type] = PreferredScriptSource
+			}
+			processes = append(processes, scripts...)
+			warnings = append(warnings, skipped...)
+		}
+
+		if inferWorkers, err := resolver.lookupBoolEnv("BP_POETRY_RUN_INFER_WORKERS"); err != nil {
+			return packit.BuildResult{}, err
+		} else if inferWorkers {
+			workers, skipped, err := resolver.ResolveWorkers(pyProject)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			warnings = append(warnings, skipped...)
+
+			if len(workers) > 0 {
+				logger.Process("Inferring worker processes")
+				logger.Subprocess("%s", workers[0].Target.Explanation)
+				for _, worker := range workers {
+					if hasProcessType(processes, worker.ProcessType) {
+						warnings = append(warnings, fmt.Sprintf("inferred process %q is not added: conflicts with another process", worker.ProcessType))
+						continue
+					}
+
+					target := worker.Target
+					if pyProject.NonPackageMode {
+						target, err = nonPackageTarget(target, pyProject.Scripts)
+						if err != nil {
+							return packit.BuildResult{}, fmt.Errorf("invalid process %q: %w", worker.ProcessType, err)
+						}
+					}
+
+					processes = append(processes, poetryRunProcess(worker.ProcessType, target, false))
+					sources[worker.ProcessType] = worker.Target.Source
+					logger.Subprocess("%s: poetry run %s", worker.ProcessType, strings.Join(target.Argv, " "))
+				}
+				logger.Break()
+			}
+		}
+
+		if len(options) > 0 {
+			for i, process := range processes {
+				processes[i] = withPoetryOptions(process, options)
+			}
+
+			logger.Process("Configuring poetry global options")
+			logger.Subprocess("Running %s <target>", strings.Join(poetryCommand(options), " "))
+			logger.Break()
+		}
+
+		settings, err := processSettings(context.WorkingDir, pyProject.PoetryRun.ProcessSettings, processes)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if len(settings) > 0 {
+			logger.Process("Configuring process settings")
+
+			env := map[string]packit.Environment{}
+			for i, process := range processes {
+				setting, ok := settings[process.Type]
+				if !ok {
+					continue
+				}
+
+				if setting.WorkingDirectory != "" {
+					processes[i].WorkingDirectory = setting.WorkingDirectory
+					logger.Subprocess("%s: runs in %s", process.Type, setting.WorkingDirectory)
+				}
+
+				for _, name := range envNames(setting.Env) {
+					if env[process.Type] == nil {
+						env[process.Type] = packit.Environment{}
+					}
+					env[process.Type].Override(name, setting.Env[name])
+					logger.Subprocess("%s: %s -> %s", process.Type, name, redact(setting.Env[name]))
+				}
+			}
+			logger.Break()
+
+			if len(env) > 0 {
+				layer, err := context.Layers.Get(ProcessEnvLayerName)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				layer, err = layer.Reset()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				layer.Launch = true
+				layer.ProcessLaunchEnv = env
+				layers = append(layers, layer)
+			}
+		}
+
+		for _, warning := range warnings {
+			logger.Process("Warning: %s", warning)
+			logger.Break()
+		}
+
+		logger.LaunchDirectProcesses(processes)
+
+		report := Report{
+			Target: ReportTarget{
+				Kind:        target.Kind,
+				Command:     append(poetryCommand(options), target.Argv...),
+				Source:      target.Source,
+				Explanation: target.Explanation,
+			},
+			Processes: []ReportProcess{},
+			Profiles:  []ReportProfile{},
+			Reload:    reload,
+			Runtime:   runtime,
+			Warnings:  append([]string{}, warnings...),
+		}
+
+		for _, process := range processes {
+			source, ok := sources[process.Type]
+			if !ok {
+				source = auxiliarySources[process.Type]
+			}
+
+			report.Processes = append(report.Processes, ReportProcess{
+				Type:    process.Type,
+				Command: process.Command,
+				Args:    process.Args,
+				Default: process.Default,
+				Source:  source,
+
+				WorkingDirectory: process.WorkingDirectory,
+				Env:              settings[process.Type].Env,
+			})
+		}
+
+		for _, name := range profileNames(profiles) {
+			report.Profiles = append(report.Profiles, ReportProfile{
+				Name:    name,
+				Command: append(poetryCommand(options), strings.Fields(profiles[name])...),
+				Source:  profileSources[name],
+			})
+		}
+
+		reportLayer, err := context.Layers.Get(ReportLayerName)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		reportLayer, err = reportLayer.Reset()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		reportLayer.Launch = true
+
+		err = writeReport(reportLayer, report)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		logger.Process("Build report")
+		for _, line := range report.Summary() {
+			logger.Subprocess("%s", line)
+		}
+		logger.Break()
+
+		layers = append(layers, reportLayer)
 
 		return packit.BuildResult{
+			Layers: layers,
 			Launch: packit.LaunchMetadata{
-				Processes: processes,
+				DirectProcesses: processes,
+				Labels:          runtime.labels(),
 			},
 		}, nil
 	}
 }
+
+// installHelper copies the named helper binary of the buildpack into the bin
+// directory of the named launch layer, so that processes can run it, and
+// returns the layer and the path of the binary.
+func installHelper(context packit.BuildContext, layerName, name string) (packit.Layer, string, error) {
+	layer, err := context.Layers.Get(layerName)
+	if err != nil {
+		return packit.Layer{}, "", err
+	}
+
+	layer, err = layer.Reset()
+	if err != nil {
+		return packit.Layer{}, "", err
+	}
+	layer.Launch = true
+
+	binary := filepath.Join(layer.Path, "bin", name)
+	err = os.MkdirAll(filepath.Dir(binary), os.ModePerm)
+	if err != nil {
+		return packit.Layer{}, "", err
+	}
+
+	err = fs.Copy(filepath.Join(context.CNBPath, "bin", name), binary)
+	if err != nil {
+		return packit.Layer{}, "", err
+	}
+
+	return layer, binary, nil
+}
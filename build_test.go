@@ -2,11 +2,15 @@ package poetryrun_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/BurntSushi/toml"
 	"github.com/paketo-buildpacks/libreload-packit"
 	"github.com/paketo-buildpacks/libreload-packit/watchexec"
 	"github.com/paketo-buildpacks/packit/v2"
@@ -28,11 +32,14 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		cnbDir     string
 		buffer     *bytes.Buffer
 
-		pyProjectParser *fakes.PyProjectParser
-		reloader        *fakes.Reloader
+		pyProjectParser  *fakes.PyProjectParser
+		lockFileParser   *fakes.LockFileParser
+		configFileParser *fakes.ConfigFileParser
+		reloader         *fakes.Reloader
 
 		build        packit.BuildFunc
 		buildContext packit.BuildContext
+		reportLayer  packit.Layer
 	)
 
 	it.Before(func() {
@@ -50,14 +57,24 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		logger := scribe.NewEmitter(buffer).WithLevel("DEBUG")
 
 		pyProjectParser = &fakes.PyProjectParser{}
-		pyProjectParser.ParseCall.Returns.String = "some-script"
+		pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+			Path:   filepath.Join(workingDir, "pyproject.toml"),
+			Exists: true,
+			Scripts: map[string]poetryrun.Script{
+				"some-script": {Name: "some-script", Type: poetryrun.ConsoleScript, Reference: "some.module:main"},
+			},
+		}
+
+		lockFileParser = &fakes.LockFileParser{}
+
+		configFileParser = &fakes.ConfigFileParser{}
 
 		reloader = &fakes.Reloader{}
 		reloader.TransformReloadableProcessesCall.Stub = func(process packit.Process, spec libreload.ReloadableProcessSpec) (packit.Process, packit.Process) {
 			return watchexec.NewWatchexecReloader().TransformReloadableProcesses(process, spec)
 		}
 
-		build = poetryrun.Build(pyProjectParser, logger, reloader)
+		build = poetryrun.Build(pyProjectParser, lockFileParser, configFileParser, logger, reloader, os.Environ)
 		buildContext = packit.BuildContext{
 			WorkingDir: workingDir,
 			CNBPath:    cnbDir,
@@ -71,6 +88,16 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			},
 			Layers: packit.Layers{Path: layersDir},
 		}
+
+		reportLayer = packit.Layer{
+			Path:             filepath.Join(layersDir, "poetry-run"),
+			Name:             "poetry-run",
+			Launch:           true,
+			SharedEnv:        packit.Environment{},
+			BuildEnv:         packit.Environment{},
+			LaunchEnv:        packit.Environment{},
+			ProcessLaunchEnv: map[string]packit.Environment{},
+		}
 	})
 
 	it.After(func() {
@@ -88,18 +115,13 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				Plan: packit.BuildpackPlan{
 					Entries: nil,
 				},
-				Layers: nil,
+				Layers: []packit.Layer{reportLayer},
 				Launch: packit.LaunchMetadata{
-					Processes: []packit.Process{
+					DirectProcesses: []packit.DirectProcess{
 						{
 							Type:    "web",
-							Command: "poetry",
-							Args: []string{
-								"run",
-								"some-script",
-							},
+							Command: []string{"poetry", "run", "some-script"},
 							Default: true,
-							Direct:  true,
 						},
 					},
 				},
@@ -126,13 +148,13 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 					Plan: packit.BuildpackPlan{
 						Entries: nil,
 					},
-					Layers: nil,
+					Layers: []packit.Layer{reportLayer},
 					Launch: packit.LaunchMetadata{
-						Processes: []packit.Process{
+						DirectProcesses: []packit.DirectProcess{
 							{
-								Type:    "reload-web",
-								Command: "watchexec",
-								Args: []string{
+								Type: "reload-web",
+								Command: []string{
+									"watchexec",
 									"--restart",
 									"--watch", workingDir,
 									"--shell", "none",
@@ -142,13 +164,10 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 									"some-script",
 								},
 								Default: true,
-								Direct:  true,
 							},
 							{
 								Type:    "web",
-								Command: "poetry",
-								Args:    []string{"run", "some-script"},
-								Direct:  true,
+								Command: []string{"poetry", "run", "some-script"},
 							},
 						},
 					},
@@ -157,6 +176,402 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("with BP_LIVE_RELOAD_STRATEGY=framework-native", func() {
+		it.Before(func() {
+			reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "framework-native")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+		})
+
+		it("adds the reload flags of uvicorn instead of wrapping the process", func() {
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "uvicorn main:app --host 0.0.0.0")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "reload-web",
+					Command: []string{"poetry", "run", "uvicorn", "--reload", "--reload-dir", workingDir},
+					Args:    []string{"main:app", "--host", "0.0.0.0"},
+					Default: true,
+				},
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "uvicorn"},
+					Args:    []string{"main:app", "--host", "0.0.0.0"},
+				},
+			}))
+			Expect(reloader.TransformReloadableProcessesCall.CallCount).To(Equal(0))
+		})
+
+		it("adds --reload to gunicorn", func() {
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{"poetry", "run", "gunicorn", "--reload"}))
+			Expect(result.Launch.DirectProcesses[0].Args).To(Equal([]string{"app:app"}))
+		})
+
+		it("adds --reload to flask run", func() {
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "flask --app app run --host 0.0.0.0")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{"poetry", "run", "flask", "--app", "app", "run", "--reload"}))
+			Expect(result.Launch.DirectProcesses[0].Args).To(Equal([]string{"--host", "0.0.0.0"}))
+		})
+
+		it("removes --noreload from Django's runserver", func() {
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "python manage.py runserver --noreload 0.0.0.0:8000")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{"poetry", "run", "python", "manage.py", "runserver"}))
+			Expect(result.Launch.DirectProcesses[0].Args).To(Equal([]string{"0.0.0.0:8000"}))
+		})
+
+		it("returns an error for a target without a native reloader", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`BP_LIVE_RELOAD_STRATEGY=framework-native does not support "some-script": use uvicorn, gunicorn, 'flask run' or Django's runserver, or set BP_LIVE_RELOAD_STRATEGY=watchexec`))
+		})
+
+		it("returns an error when run profiles are configured", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{"dev": "uvicorn main:app"}
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError("BP_LIVE_RELOAD_STRATEGY=framework-native does not support run profiles: set BP_LIVE_RELOAD_STRATEGY=watchexec"))
+		})
+	})
+
+	context("with BP_LIVE_RELOAD_STRATEGY=none", func() {
+		it.Before(func() {
+			reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "none")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+		})
+
+		it("does not add a reloadable process", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "some-script"},
+					Default: true,
+				},
+			}))
+		})
+	})
+
+	context("with graceful restart settings", func() {
+		it.Before(func() {
+			reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			Expect(os.Setenv("BP_LIVE_RELOAD_STOP_SIGNAL", "term")).To(Succeed())
+			Expect(os.Setenv("BP_LIVE_RELOAD_STOP_TIMEOUT", "10s")).To(Succeed())
+			Expect(os.Setenv("BP_LIVE_RELOAD_DEBOUNCE", "500ms")).To(Succeed())
+			Expect(os.Setenv("BP_LIVE_RELOAD_CLEAR_SCREEN", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_STOP_SIGNAL")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_STOP_TIMEOUT")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_DEBOUNCE")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_CLEAR_SCREEN")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_ON_CHANGE")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_SIGNAL")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+		})
+
+		it("passes them to watchexec and lists them with the processes", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[0]).To(Equal(packit.DirectProcess{
+				Type: "reload-web",
+				Command: []string{
+					"watchexec",
+					"--stop-signal", "SIGTERM",
+					"--stop-timeout", "10s",
+					"--debounce", "500ms",
+					"--clear",
+					"--restart",
+					"--watch", workingDir,
+					"--shell", "none",
+					"--",
+					"poetry", "run", "some-script",
+				},
+				Default: true,
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("reload-web (default): watchexec --stop-signal SIGTERM --stop-timeout 10s --debounce 500ms --clear --restart --watch %s", workingDir)))
+		})
+
+		context("when BP_LIVE_RELOAD_ON_CHANGE=signal", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_ON_CHANGE", "signal")).To(Succeed())
+			})
+
+			it("signals the process with SIGHUP instead of restarting it", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{
+					"watchexec",
+					"--stop-signal", "SIGTERM",
+					"--stop-timeout", "10s",
+					"--debounce", "500ms",
+					"--on-busy-update", "signal", "--signal", "SIGHUP",
+					"--clear",
+					"--watch", workingDir,
+					"--shell", "none",
+					"--",
+					"poetry", "run", "some-script",
+				}))
+			})
+
+			context("when BP_LIVE_RELOAD_SIGNAL is set", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_LIVE_RELOAD_SIGNAL", "SIGUSR1")).To(Succeed())
+				})
+
+				it("sends that signal", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Launch.DirectProcesses[0].Command).To(ContainElements("--signal", "SIGUSR1"))
+				})
+			})
+		})
+
+		context("with BP_LIVE_RELOAD_STRATEGY=framework-native", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "framework-native")).To(Succeed())
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+			})
+
+			it("ignores them with a warning", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Args).To(Equal([]string{"app:app"}))
+				Expect(buffer.String()).To(ContainSubstring("Warning: BP_LIVE_RELOAD_STOP_SIGNAL, BP_LIVE_RELOAD_STOP_TIMEOUT, BP_LIVE_RELOAD_DEBOUNCE, BP_LIVE_RELOAD_ON_CHANGE, BP_LIVE_RELOAD_SIGNAL and BP_LIVE_RELOAD_CLEAR_SCREEN only apply to BP_LIVE_RELOAD_STRATEGY=watchexec and are ignored"))
+				Expect(buffer.String()).To(ContainSubstring("    warning = BP_LIVE_RELOAD_STOP_SIGNAL"))
+			})
+		})
+	})
+
+	context("when BP_LIVE_RELOAD_SYNC_DEPENDENCIES is true", func() {
+		var binary string
+
+		it.Before(func() {
+			reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			Expect(os.Setenv("BP_LIVE_RELOAD_SYNC_DEPENDENCIES", "true")).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(cnbDir, "bin"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(cnbDir, "bin", "sync-dependencies"), []byte("sync-binary"), 0755)).To(Succeed())
+
+			binary = filepath.Join(layersDir, "sync-dependencies", "bin", "sync-dependencies")
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_SYNC_DEPENDENCIES")).To(Succeed())
+			Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+		})
+
+		it("runs the reloadable process under the dependency sync, which watchexec ignores", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type: "reload-web",
+					Command: []string{
+						"watchexec",
+						"--restart",
+						"--watch", workingDir,
+						"--ignore", filepath.Join(workingDir, "pyproject.toml"),
+						"--ignore", filepath.Join(workingDir, "poetry.lock"),
+						"--shell", "none",
+						"--",
+						binary,
+						"--watch", filepath.Join(workingDir, "pyproject.toml"),
+						"--watch", filepath.Join(workingDir, "poetry.lock"),
+						"--",
+						"poetry", "run", "some-script",
+					},
+					Default: true,
+				},
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "some-script"},
+				},
+			}))
+
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("sync-dependencies"))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(binary).To(BeARegularFile())
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring dependency sync",
+				fmt.Sprintf("    Running poetry install --sync when %s or %s change", filepath.Join(workingDir, "pyproject.toml"), filepath.Join(workingDir, "poetry.lock")),
+				"      The running process is kept when the install fails",
+			))
+		})
+
+		context("with BP_LIVE_RELOAD_STRATEGY=framework-native", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "framework-native")).To(Succeed())
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+			})
+
+			it("runs the framework's reloader under the dependency sync", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0]).To(Equal(packit.DirectProcess{
+					Type: "reload-web",
+					Command: []string{
+						binary,
+						"--watch", filepath.Join(workingDir, "pyproject.toml"),
+						"--watch", filepath.Join(workingDir, "poetry.lock"),
+						"--",
+						"poetry", "run", "gunicorn", "--reload",
+					},
+					Args:    []string{"app:app"},
+					Default: true,
+				}))
+			})
+		})
+
+		context("when a stop signal and timeout are configured", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_STOP_SIGNAL", "SIGINT")).To(Succeed())
+				Expect(os.Setenv("BP_LIVE_RELOAD_STOP_TIMEOUT", "30s")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_LIVE_RELOAD_STOP_SIGNAL")).To(Succeed())
+				Expect(os.Unsetenv("BP_LIVE_RELOAD_STOP_TIMEOUT")).To(Succeed())
+			})
+
+			it("stops the process with them in the dependency sync too", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{
+					"watchexec",
+					"--stop-signal", "SIGINT",
+					"--stop-timeout", "30s",
+					"--restart",
+					"--watch", workingDir,
+					"--ignore", filepath.Join(workingDir, "pyproject.toml"),
+					"--ignore", filepath.Join(workingDir, "poetry.lock"),
+					"--shell", "none",
+					"--",
+					binary,
+					"--watch", filepath.Join(workingDir, "pyproject.toml"),
+					"--watch", filepath.Join(workingDir, "poetry.lock"),
+					"--stop-signal", "SIGINT",
+					"--stop-timeout", "30s",
+					"--",
+					"poetry", "run", "some-script",
+				}))
+			})
+		})
+
+		context("when live reload is not enabled", func() {
+			it.Before(func() {
+				reloader.ShouldEnableLiveReloadCall.Returns.Bool = false
+			})
+
+			it("ignores it with a warning", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(HaveLen(1))
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(buffer.String()).To(ContainSubstring("Warning: BP_LIVE_RELOAD_SYNC_DEPENDENCIES only applies when live reload is enabled and is ignored"))
+			})
+		})
+
+		context("when poetry global options are configured", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_POETRY_OPTIONS", "--no-plugins --directory backend")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_POETRY_OPTIONS")).To(Succeed())
+			})
+
+			it("installs with the same options and watches the files of the project directory", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{
+					"watchexec",
+					"--restart",
+					"--watch", workingDir,
+					"--ignore", filepath.Join(workingDir, "backend", "pyproject.toml"),
+					"--ignore", filepath.Join(workingDir, "backend", "poetry.lock"),
+					"--shell", "none",
+					"--",
+					binary,
+					"--watch", filepath.Join(workingDir, "backend", "pyproject.toml"),
+					"--watch", filepath.Join(workingDir, "backend", "poetry.lock"),
+					"--install", "poetry --no-plugins --directory backend install --sync",
+					"--",
+					"poetry", "--no-plugins", "--directory", "backend", "run", "some-script",
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Running poetry --no-plugins --directory backend install --sync when %s or %s change", filepath.Join(workingDir, "backend", "pyproject.toml"), filepath.Join(workingDir, "backend", "poetry.lock"))))
+			})
+
+			context("when --project is also configured", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_POETRY_RUN_POETRY_OPTIONS", "-C backend --project=api")).To(Succeed())
+				})
+
+				it("watches the files of the project relative to the directory", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Launch.DirectProcesses[0].Command).To(ContainElements(
+						filepath.Join(workingDir, "backend", "api", "pyproject.toml"),
+						filepath.Join(workingDir, "backend", "api", "poetry.lock"),
+						"poetry -C backend --project=api install --sync",
+					))
+				})
+			})
+		})
+
+		context("when the dependency sync cannot be installed", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(cnbDir, "bin", "sync-dependencies"))).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to install the dependency sync")))
+			})
+		})
+	})
+
 	context("with BP_POETRY_RUN_TARGET set", func() {
 		it.Before(func() {
 			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "a custom command")).To(Succeed())
@@ -166,7 +581,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
 		})
 
-		it("will use the value of BP_POETRY_RUN_TARGET and not use the pyproject.toml parser", func() {
+		it("will use the value of BP_POETRY_RUN_TARGET and not the pyproject.toml script", func() {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -174,20 +589,14 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				Plan: packit.BuildpackPlan{
 					Entries: nil,
 				},
-				Layers: nil,
+				Layers: []packit.Layer{reportLayer},
 				Launch: packit.LaunchMetadata{
-					Processes: []packit.Process{
+					DirectProcesses: []packit.DirectProcess{
 						{
 							Type:    "web",
-							Command: "poetry",
-							Args: []string{
-								"run",
-								"a",
-								"custom",
-								"command",
-							},
+							Command: []string{"poetry", "run", "a"},
+							Args:    []string{"custom", "command"},
 							Default: true,
-							Direct:  true,
 						},
 					},
 				},
@@ -199,7 +608,33 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				ContainSubstring("Assigning launch processes:"),
 				ContainSubstring("web (default): poetry run a custom command"),
 			))
-			Expect(pyProjectParser.ParseCall.CallCount).To(Equal(0))
+			Expect(pyProjectParser.ParseCall.CallCount).To(Equal(1))
+		})
+
+		context("when pyproject.toml is not valid TOML", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.Error = poetryrun.DetectionError{
+					Reason: poetryrun.InvalidTOML,
+					Path:   "some-path",
+					Line:   3,
+					Column: 7,
+					Err:    errors.New("expected a value"),
+				}
+			})
+
+			it("still builds the explicit target", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+					{
+						Type:    "web",
+						Command: []string{"poetry", "run", "a"},
+						Args:    []string{"custom", "command"},
+						Default: true,
+					},
+				}))
+			})
 		})
 
 		context("when live reload is enabled", func() {
@@ -215,13 +650,13 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 					Plan: packit.BuildpackPlan{
 						Entries: nil,
 					},
-					Layers: nil,
+					Layers: []packit.Layer{reportLayer},
 					Launch: packit.LaunchMetadata{
-						Processes: []packit.Process{
+						DirectProcesses: []packit.DirectProcess{
 							{
-								Type:    "reload-web",
-								Command: "watchexec",
-								Args: []string{
+								Type: "reload-web",
+								Command: []string{
+									"watchexec",
 									"--restart",
 									"--watch", workingDir,
 									"--shell", "none",
@@ -229,22 +664,14 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 									"poetry",
 									"run",
 									"a",
-									"custom",
-									"command",
 								},
+								Args:    []string{"custom", "command"},
 								Default: true,
-								Direct:  true,
 							},
 							{
 								Type:    "web",
-								Command: "poetry",
-								Args: []string{
-									"run",
-									"a",
-									"custom",
-									"command",
-								},
-								Direct: true,
+								Command: []string{"poetry", "run", "a"},
+								Args:    []string{"custom", "command"},
 							},
 						},
 					},
@@ -253,21 +680,1745 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("failure cases", func() {
-		context("when BP_POETRY_RUN_TARGET is not set", func() {
+	context("with a target configured in pyproject.toml", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Target = "gunicorn some_app:app"
+		})
+
+		it("uses the configured target", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "gunicorn"},
+					Args:    []string{"some_app:app"},
+					Default: true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				ContainSubstring("Finding the poetry run target"),
+				ContainSubstring("Found [tool.paketo.poetry-run] target=gunicorn some_app:app"),
+			))
+		})
+	})
+
+	context("when poetry.lock is checked", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.ContentHash = "0123456789abcdef"
+			lockFileParser.ParseCall.Returns.PoetryLock = poetryrun.PoetryLock{
+				Metadata: poetryrun.PoetryLockMetadata{LockVersion: "2.1", ContentHash: "0123456789abcdef"},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_LOCK_CHECK")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_VERSION")).To(Succeed())
+		})
+
+		it("reads poetry.lock from the app directory and warns about nothing when it is fresh", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lockFileParser.ParseCall.Receives.String).To(Equal(filepath.Join(workingDir, "poetry.lock")))
+			Expect(buffer.String()).NotTo(ContainSubstring("Warning:"))
+		})
+
+		context("when the content-hash does not match pyproject.toml", func() {
 			it.Before(func() {
-				Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+				lockFileParser.ParseCall.Returns.PoetryLock.Metadata.ContentHash = "fedcba9876543210"
 			})
 
-			context(" and the pyproject.toml parser returns an error", func() {
-				it.Before(func() {
-					pyProjectParser.ParseCall.Returns.Error = fmt.Errorf("some error")
-				})
+			it("warns", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
 
-				it("returns the error", func() {
-					_, err := build(buildContext)
-					Expect(err).To(MatchError(ContainSubstring("some error")))
-				})
+				Expect(buffer.String()).To(ContainSubstring("Warning: poetry.lock is out of date: its content-hash fedcba987654 does not match 0123456789ab of pyproject.toml, run 'poetry lock' to update it"))
+				Expect(buffer.String()).To(ContainSubstring("    warning = poetry.lock is out of date"))
+			})
+
+			it("fails the build with BP_POETRY_RUN_LOCK_CHECK=fail", func() {
+				Expect(os.Setenv("BP_POETRY_RUN_LOCK_CHECK", "fail")).To(Succeed())
+
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed the poetry.lock check: poetry.lock is out of date: its content-hash fedcba987654 does not match 0123456789ab of pyproject.toml, run 'poetry lock' to update it: set BP_POETRY_RUN_LOCK_CHECK=warn to build anyway"))
+			})
+
+			it("skips the check with BP_POETRY_RUN_LOCK_CHECK=off", func() {
+				Expect(os.Setenv("BP_POETRY_RUN_LOCK_CHECK", "off")).To(Succeed())
+
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(lockFileParser.ParseCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).NotTo(ContainSubstring("Warning:"))
+			})
+		})
+
+		context("when BP_POETRY_VERSION is not set", func() {
+			it.Before(func() {
+				lockFileParser.ParseCall.Returns.PoetryLock.Metadata.LockVersion = "9.0"
+			})
+
+			it("skips the lock-version check", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).NotTo(ContainSubstring("Warning:"))
+			})
+		})
+
+		context("when the lock-version cannot be read by the Poetry version of BP_POETRY_VERSION", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_VERSION", "1.2.2")).To(Succeed())
+			})
+
+			it("warns", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Warning: poetry.lock has lock-version 2.1, which Poetry 1.2.2 cannot read: it supports lock-version 1.1 and older"))
+			})
+		})
+
+		context("when the lock-version is newer than Poetry 1.3 and later support", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_VERSION", "1.3.0")).To(Succeed())
+			})
+
+			it("warns that lock-version 2.0 is supported", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Warning: poetry.lock has lock-version 2.1, which is newer than the lock-version 2.0 of Poetry 1.3.0 and may not be compatible"))
+			})
+		})
+
+		context("when the lock-version is supported", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_VERSION", "2.*")).To(Succeed())
+			})
+
+			it("does not warn", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).NotTo(ContainSubstring("Warning:"))
+			})
+		})
+	})
+
+	context("when the preferred script names pick one of several scripts", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_POETRY_RUN_PREFER_SCRIPTS", "true")).To(Succeed())
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["web"] = poetryrun.Script{Name: "web", Type: poetryrun.ConsoleScript, Reference: "some.module:web"}
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["my worker"] = poetryrun.Script{Name: "my worker", Type: poetryrun.ConsoleScript, Reference: "some.module:work"}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_PREFER_SCRIPTS")).To(Succeed())
+		})
+
+		it("runs it by default and assigns the other scripts non-default process types", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "web"},
+					Default: true,
+				},
+				{
+					Type:    "my-worker",
+					Command: []string{"poetry", "run", "my worker"},
+				},
+				{
+					Type:    "some-script",
+					Command: []string{"poetry", "run", "some-script"},
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Selected script web from the preferred scripts",
+				"    The other scripts are assigned non-default process types",
+			))
+			Expect(buffer.String()).To(ContainSubstring("    process.some-script.source = preferred-script"))
+		})
+
+		it("uses the process types mapped in pyproject.toml", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessTypes = map[string]string{"my worker": "worker"}
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[1]).To(Equal(packit.DirectProcess{
+				Type:    "worker",
+				Command: []string{"poetry", "run", "my worker"},
+			}))
+		})
+
+		it("returns an error when two scripts map to the same process type", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["My_Worker"] = poetryrun.Script{Name: "My_Worker", Type: poetryrun.ConsoleScript, Reference: "some.module:work"}
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`scripts "My_Worker" and "my worker" both map to process type "my-worker": map one of them to another process type under [tool.paketo.poetry-run.process-types]`))
+		})
+
+		it("returns an error when a mapped process type is invalid", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessTypes = map[string]string{"my worker": "my worker"}
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`invalid process type "my worker" for script "my worker": process types may only contain letters, numbers, '.', '_' and '-'`))
+		})
+
+		it("returns an error when no process type can be derived from a script key", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["🚀"] = poetryrun.Script{Name: "🚀", Type: poetryrun.ConsoleScript, Reference: "some.module:launch"}
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`failed to derive a process type from script "🚀": map it to one under [tool.paketo.poetry-run.process-types]`))
+		})
+
+		it("lets auxiliary processes take precedence over scripts", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{"some-script": "python -m some.module"}
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[1]).To(Equal(packit.DirectProcess{
+				Type:    "some-script",
+				Command: []string{"poetry", "run", "python", "-m", "some.module"},
+			}))
+			Expect(result.Launch.DirectProcesses).To(HaveLen(3))
+			Expect(buffer.String()).To(ContainSubstring(`Warning: script "some-script" is not assigned process type "some-script": conflicts with another process`))
+		})
+	})
+
+	context("when pyproject.toml sets package-mode = false", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.NonPackageMode = true
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+		})
+
+		it("runs the callable of the script with python -c", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "python", "-c", "import sys; from some.module import main; sys.exit(main())"},
+					Default: true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Found package-mode = false in pyproject.toml",
+				"    Poetry does not install script some-script, running python -c 'import sys; from some.module import main; sys.exit(main())' instead",
+			))
+		})
+
+		it("runs a script that references a module with python -m and keeps its default args", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["some-script"] = poetryrun.Script{Name: "some-script", Type: poetryrun.ConsoleScript, Reference: "some.module"}
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "some-script --port 8080")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "python", "-m", "some.module"},
+					Args:    []string{"--port", "8080"},
+					Default: true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"    Poetry does not install script some-script, running python -m some.module instead",
+			))
+		})
+
+		it("runs other targets as-is", func() {
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{"poetry", "run", "gunicorn"}))
+			Expect(buffer.String()).To(ContainLines(
+				"    Running poetry run gunicorn app:app as-is",
+			))
+		})
+
+		it("returns an error for a file script", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["some-script"] = poetryrun.Script{Name: "some-script", Type: poetryrun.FileScript, Reference: "bin/run.sh"}
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`failed to run script "some-script": pyproject.toml sets package-mode = false, so Poetry does not install scripts, and the file script bin/run.sh cannot be translated into a python invocation: set BP_POETRY_RUN_TARGET to the command that runs it`))
+		})
+
+		it("translates auxiliary and health processes that name a script", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{"release": "some-script --migrate"}
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health = poetryrun.HealthConfig{Target: "some-script --check"}
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "gunicorn"},
+					Args:    []string{"app:app"},
+					Default: true,
+				},
+				{
+					Type:    "health",
+					Command: []string{"poetry", "run", "python", "-c", "import sys; from some.module import main; sys.exit(main())"},
+					Args:    []string{"--check"},
+				},
+				{
+					Type:    "release",
+					Command: []string{"poetry", "run", "python", "-c", "import sys; from some.module import main; sys.exit(main())"},
+					Args:    []string{"--migrate"},
+				},
+			}))
+		})
+
+		it("returns an error for an auxiliary process that names a file script", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.Scripts["other-script"] = poetryrun.Script{Name: "other-script", Type: poetryrun.FileScript, Reference: "bin/run.sh"}
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{"release": "other-script"}
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(ContainSubstring(`invalid process "release": failed to run script "other-script"`)))
+		})
+
+		it("returns an error when run profiles are configured for a callable", func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{"dev": "python -m some.module"}
+
+			_, err := build(buildContext)
+			Expect(err).To(MatchError("run profiles do not support a script that runs with python -c in non-package mode: set BP_POETRY_RUN_TARGET to a command that runs it, e.g. 'python -m <module>'"))
+		})
+	})
+
+	context("when BP_DEBUG_ENABLED is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_DEBUG_ENABLED", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_DEBUG_ENABLED")).To(Succeed())
+		})
+
+		it("adds a debug process that runs the script callable under debugpy", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "some-script"},
+					Default: true,
+				},
+				{
+					Type: "debug",
+					Command: []string{
+						"bash", "-c",
+						`exec poetry run python -m debugpy --listen 0.0.0.0:${BPL_DEBUG_PORT:-5678} -c 'import sys; from some.module import main; sys.exit(main())' "$@"`,
+						"debug",
+					},
+				},
+			}))
+
+			Expect(pyProjectParser.ParseCall.Receives.String).To(Equal(filepath.Join(workingDir, "pyproject.toml")))
+
+			Expect(buffer.String()).To(ContainLines(
+				ContainSubstring("Configuring the debug process"),
+				ContainSubstring("Listening for debugpy clients on BPL_DEBUG_PORT (default 5678)"),
+			))
+		})
+
+		context("when the script references a module", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.Scripts = map[string]poetryrun.Script{
+					"some-script": {Name: "some-script", Type: poetryrun.ConsoleScript, Reference: "some.module"},
+				}
+			})
+
+			it("runs the module under debugpy", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[1].Command[2]).To(Equal(`exec poetry run python -m debugpy --listen 0.0.0.0:${BPL_DEBUG_PORT:-5678} -m some.module "$@"`))
+			})
+		})
+
+		context("when the script references a file", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.Scripts = map[string]poetryrun.Script{
+					"some-script": {Name: "some-script", Type: poetryrun.FileScript, Reference: "bin/some script.py"},
+				}
+			})
+
+			it("runs the file under debugpy", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[1].Command[2]).To(Equal(`exec poetry run python -m debugpy --listen 0.0.0.0:${BPL_DEBUG_PORT:-5678} 'bin/some script.py' "$@"`))
+			})
+		})
+
+		context("when BP_DEBUG_WAIT_FOR_CLIENT is true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_DEBUG_WAIT_FOR_CLIENT", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_DEBUG_WAIT_FOR_CLIENT")).To(Succeed())
+			})
+
+			it("waits for a debugger to attach before starting", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[1].Command[2]).To(Equal(`exec poetry run python -m debugpy --listen 0.0.0.0:${BPL_DEBUG_PORT:-5678} --wait-for-client -c 'import sys; from some.module import main; sys.exit(main())' "$@"`))
+			})
+		})
+
+		context("when BP_POETRY_RUN_TARGET runs a server executable", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET", "uvicorn main:app --port 8080")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+			})
+
+			it("runs the module of the executable under debugpy", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[1]).To(Equal(packit.DirectProcess{
+					Type: "debug",
+					Command: []string{
+						"bash", "-c",
+						`exec poetry run python -m debugpy --listen 0.0.0.0:${BPL_DEBUG_PORT:-5678} -m uvicorn "$@"`,
+						"debug",
+					},
+					Args: []string{"main:app", "--port", "8080"},
+				}))
+			})
+		})
+
+		context("when the target cannot be run under debugpy", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET", "some-binary --some-flag")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+			})
+
+			it("skips the debug process with a warning", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(HaveLen(1))
+				Expect(buffer.String()).To(ContainSubstring(`Warning: BP_DEBUG_ENABLED is set but no debug process is added: "some-binary" is neither a python invocation, a script defined in pyproject.toml nor an executable that debugpy can run as a module`))
+			})
+		})
+
+		context("when BP_POETRY_RUN_TARGET runs a python module", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET", "python -m some.module --some-flag")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+			})
+
+			it("runs the module under debugpy and passes its arguments as default args", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[1]).To(Equal(packit.DirectProcess{
+					Type: "debug",
+					Command: []string{
+						"bash", "-c",
+						`exec poetry run python -m debugpy --listen 0.0.0.0:${BPL_DEBUG_PORT:-5678} -m some.module "$@"`,
+						"debug",
+					},
+					Args: []string{"--some-flag"},
+				}))
+			})
+		})
+
+		context("when live reload is enabled", func() {
+			it.Before(func() {
+				reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			})
+
+			it("adds the debug process after the reloadable processes", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(HaveLen(3))
+				Expect(result.Launch.DirectProcesses[0].Type).To(Equal("reload-web"))
+				Expect(result.Launch.DirectProcesses[1].Type).To(Equal("web"))
+				Expect(result.Launch.DirectProcesses[2].Type).To(Equal("debug"))
+			})
+		})
+	})
+
+	context("when auxiliary processes are configured", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+				"release": "alembic upgrade head",
+				"shell":   "python",
+			}
+
+			Expect(os.Setenv("BP_POETRY_RUN_PROCESS_RELEASE", "python manage.py migrate")).To(Succeed())
+			Expect(os.Setenv("BP_POETRY_RUN_PROCESS_DB_SEED", "python seed.py")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_PROCESS_RELEASE")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_PROCESS_DB_SEED")).To(Succeed())
+		})
+
+		it("adds a non-default 'poetry run' process for each, preferring the environment", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "some-script"},
+					Default: true,
+				},
+				{
+					Type:    "db-seed",
+					Command: []string{"poetry", "run", "python"},
+					Args:    []string{"seed.py"},
+				},
+				{
+					Type:    "release",
+					Command: []string{"poetry", "run", "python"},
+					Args:    []string{"manage.py", "migrate"},
+				},
+				{
+					Type:    "shell",
+					Command: []string{"poetry", "run", "python"},
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				ContainSubstring("Found auxiliary processes"),
+				ContainSubstring("db-seed: poetry run python seed.py"),
+				ContainSubstring("release: poetry run python manage.py migrate"),
+				ContainSubstring("shell: poetry run python"),
+			))
+		})
+	})
+
+	context("when BP_POETRY_RUN_EXPAND_ENV is true", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+				"release": "alembic -c ${ALEMBIC_CONFIG} upgrade head",
+			}
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{
+				"prod": "gunicorn ${APP_MODULE}:app --workers ${WORKERS:-2}",
+			}
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health.Target = "python -m ${APP_MODULE}.health"
+
+			Expect(os.Setenv("BP_POETRY_RUN_EXPAND_ENV", "true")).To(Succeed())
+			Expect(os.Setenv("APP_MODULE", "some_app")).To(Succeed())
+			Expect(os.Setenv("ALEMBIC_CONFIG", "db/alembic.ini")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_EXPAND_ENV")).To(Succeed())
+			Expect(os.Unsetenv("APP_MODULE")).To(Succeed())
+			Expect(os.Unsetenv("ALEMBIC_CONFIG")).To(Succeed())
+		})
+
+		it("expands variables in the profile, health and auxiliary targets", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("POETRY_RUN_TARGET_PROD.default", "gunicorn some_app:app --workers 2"))
+			Expect(result.Launch.DirectProcesses).To(ContainElements(
+				packit.DirectProcess{
+					Type:    "health",
+					Command: []string{"poetry", "run", "python", "-m", "some_app.health"},
+				},
+				packit.DirectProcess{
+					Type:    "release",
+					Command: []string{"poetry", "run", "alembic"},
+					Args:    []string{"-c", "db/alembic.ini", "upgrade", "head"},
+				},
+			))
+		})
+	})
+
+	context("when process settings are configured", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "src"), os.ModePerm)).To(Succeed())
+
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+				"worker": "celery -A some_app worker",
+			}
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessSettings = map[string]poetryrun.ProcessSettings{
+				"web": {
+					WorkingDirectory: "./src/",
+					Env:              map[string]string{"DJANGO_SETTINGS_MODULE": "some_app.settings.web"},
+				},
+				"worker": {
+					Env: map[string]string{"DJANGO_SETTINGS_MODULE": "some_app.settings.worker"},
+				},
+			}
+		})
+
+		it("sets the working directory of the processes and their env in a process-scoped launch layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:             "web",
+					Command:          []string{"poetry", "run", "some-script"},
+					Default:          true,
+					WorkingDirectory: filepath.Join(workingDir, "src"),
+				},
+				{
+					Type:    "worker",
+					Command: []string{"poetry", "run", "celery"},
+					Args:    []string{"-A", "some_app", "worker"},
+				},
+			}))
+
+			Expect(result.Layers).To(HaveLen(2))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("process-env"))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.LaunchEnv).To(BeEmpty())
+			Expect(layer.ProcessLaunchEnv).To(Equal(map[string]packit.Environment{
+				"web":    {"DJANGO_SETTINGS_MODULE.override": "some_app.settings.web"},
+				"worker": {"DJANGO_SETTINGS_MODULE.override": "some_app.settings.worker"},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring process settings",
+				fmt.Sprintf("    web: runs in %s", filepath.Join(workingDir, "src")),
+				"    web: DJANGO_SETTINGS_MODULE -> ********",
+				"    worker: DJANGO_SETTINGS_MODULE -> ********",
+			))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("process.web.working-directory = %s", filepath.Join(workingDir, "src"))))
+			Expect(buffer.String()).To(ContainSubstring("process.worker.env.DJANGO_SETTINGS_MODULE = ********"))
+			Expect(buffer.String()).NotTo(ContainSubstring("some_app.settings.worker"))
+		})
+
+		context("when live reload is enabled", func() {
+			it.Before(func() {
+				reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			})
+
+			it("applies the settings to the reloadable process too", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Type).To(Equal("reload-web"))
+				Expect(result.Launch.DirectProcesses[0].WorkingDirectory).To(Equal(filepath.Join(workingDir, "src")))
+				Expect(result.Launch.DirectProcesses[1].Type).To(Equal("web"))
+				Expect(result.Launch.DirectProcesses[1].WorkingDirectory).To(Equal(filepath.Join(workingDir, "src")))
+				Expect(result.Layers[0].ProcessLaunchEnv).To(HaveKey("reload-web"))
+			})
+		})
+	})
+
+	context("when poetry global options are configured", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.PoetryOptions = "--no-ansi"
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+				"release": "alembic upgrade head",
+			}
+			Expect(os.Setenv("BP_POETRY_RUN_POETRY_OPTIONS", "--no-plugins --quiet --directory=backend")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_POETRY_OPTIONS")).To(Succeed())
+		})
+
+		it("places the options from the environment before run", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "--no-plugins", "--quiet", "--directory=backend", "run", "some-script"},
+					Default: true,
+				},
+				{
+					Type:    "release",
+					Command: []string{"poetry", "--no-plugins", "--quiet", "--directory=backend", "run", "alembic"},
+					Args:    []string{"upgrade", "head"},
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring poetry global options",
+				"    Running poetry --no-plugins --quiet --directory=backend run <target>",
+			))
+			Expect(buffer.String()).To(ContainSubstring("web (default): poetry --no-plugins --quiet --directory=backend run some-script"))
+			Expect(buffer.String()).To(ContainSubstring("target.command = poetry --no-plugins --quiet --directory=backend run some-script"))
+		})
+
+		context("when BP_POETRY_RUN_POETRY_OPTIONS is not set", func() {
+			it.Before(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_POETRY_OPTIONS")).To(Succeed())
+			})
+
+			it("uses the options from pyproject.toml", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{"poetry", "--no-ansi", "run", "some-script"}))
+			})
+		})
+
+		context("when live reload is enabled", func() {
+			it.Before(func() {
+				reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			})
+
+			it("places the options before run in the wrapped command", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				reloadable := result.Launch.DirectProcesses[0]
+				Expect(reloadable.Type).To(Equal("reload-web"))
+				Expect(strings.Join(reloadable.Command, " ")).To(ContainSubstring("poetry --no-plugins --quiet --directory=backend run some-script"))
+			})
+		})
+
+		context("when BP_DEBUG_ENABLED is true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_DEBUG_ENABLED", "true")).To(Succeed())
+				Expect(os.Setenv("BP_POETRY_RUN_POETRY_OPTIONS", "--directory backend --no-plugins")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_DEBUG_ENABLED")).To(Succeed())
+			})
+
+			it("places the options before run in the bash script of the debug process", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				debug := result.Launch.DirectProcesses[1]
+				Expect(debug.Type).To(Equal("debug"))
+				Expect(debug.Command[2]).To(HavePrefix("exec poetry --directory backend --no-plugins run python -m debugpy"))
+			})
+		})
+
+		context("when run profiles are configured", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{"dev": "python manage.py runserver"}
+			})
+
+			it("places the options before run in the bash script of the profile process", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses[0].Command).To(Equal([]string{"bash", "-c", `set -f; exec poetry --no-plugins --quiet --directory=backend run $POETRY_RUN_TARGET "$@"`, "web"}))
+				Expect(buffer.String()).To(ContainSubstring("dev: poetry --no-plugins --quiet --directory=backend run python manage.py runserver"))
+			})
+		})
+	})
+
+	context("when BP_POETRY_RUN_INFER_WORKERS is true", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "tasks.py"), nil, 0644)).To(Succeed())
+
+			pyProjectParser.ParseCall.Returns.PyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+				"main": {{Name: "celery", Constraint: "^5.3"}},
+			}
+			Expect(os.Setenv("BP_POETRY_RUN_INFER_WORKERS", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_INFER_WORKERS")).To(Succeed())
+		})
+
+		it("adds non-default worker and beat processes for the located app module", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"poetry", "run", "some-script"},
+					Default: true,
+				},
+				{
+					Type:    "beat",
+					Command: []string{"poetry", "run", "celery"},
+					Args:    []string{"-A", "tasks", "beat", "--loglevel", "INFO"},
+				},
+				{
+					Type:    "worker",
+					Command: []string{"poetry", "run", "celery"},
+					Args:    []string{"-A", "tasks", "worker", "--loglevel", "INFO"},
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Inferring worker processes",
+				"    Found celery in pyproject.toml and Celery app module tasks.py",
+				"    beat: poetry run celery -A tasks beat --loglevel INFO",
+				"    worker: poetry run celery -A tasks worker --loglevel INFO",
+			))
+			Expect(buffer.String()).To(ContainSubstring("process.worker.source = worker"))
+		})
+
+		context("when a process of the same type is configured", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+					"worker": "celery -A some_app.queue worker",
+				}
+			})
+
+			it("keeps the configured process and warns", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(HaveLen(3))
+				Expect(result.Launch.DirectProcesses[1].Type).To(Equal("worker"))
+				Expect(result.Launch.DirectProcesses[1].Args).To(Equal([]string{"-A", "some_app.queue", "worker"}))
+				Expect(result.Launch.DirectProcesses[2].Type).To(Equal("beat"))
+
+				Expect(buffer.String()).To(ContainSubstring(`Warning: inferred process "worker" is not added: conflicts with another process`))
+			})
+		})
+
+		context("when no app module can be located", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "tasks.py"))).To(Succeed())
+			})
+
+			it("adds no worker processes and warns", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(HaveLen(1))
+				Expect(buffer.String()).To(ContainSubstring("Warning: found celery in pyproject.toml but no app module to run a worker for: set BP_POETRY_RUN_WORKER_MODULE"))
+			})
+		})
+	})
+
+	context("when the project depends on a task queue and BP_POETRY_RUN_INFER_WORKERS is not set", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "tasks.py"), nil, 0644)).To(Succeed())
+
+			pyProjectParser.ParseCall.Returns.PyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+				"main": {{Name: "celery"}},
+			}
+		})
+
+		it("adds no worker processes", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.DirectProcesses).To(HaveLen(1))
+			Expect(buffer.String()).NotTo(ContainSubstring("Inferring worker processes"))
+		})
+	})
+
+	context("when run profiles are configured", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{
+				"dev":  "python manage.py runserver",
+				"prod": "gunicorn some_app:app",
+			}
+
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET_PROD", "gunicorn  some_app:app --workers 4")).To(Succeed())
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET_LOAD_TEST", "locust")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET_PROD")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET_LOAD_TEST")).To(Succeed())
+		})
+
+		it("bakes the profiles into a launch layer and runs the one selected at launch", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(2))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("profiles"))
+			Expect(layer.Path).To(Equal(filepath.Join(layersDir, "profiles")))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Cache).To(BeFalse())
+			Expect(layer.LaunchEnv).To(Equal(packit.Environment{
+				"POETRY_RUN_TARGET.default":           "some-script",
+				"POETRY_RUN_TARGET_DEV.default":       "python manage.py runserver",
+				"POETRY_RUN_TARGET_PROD.default":      "gunicorn some_app:app --workers 4",
+				"POETRY_RUN_TARGET_LOAD_TEST.default": "locust",
+			}))
+			Expect(layer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "select-profile")}))
+
+			Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+				{
+					Type:    "web",
+					Command: []string{"bash", "-c", `set -f; exec poetry run $POETRY_RUN_TARGET "$@"`, "web"},
+					Default: true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring run profiles",
+				"    default: poetry run some-script",
+				"    dev: poetry run python manage.py runserver",
+				"    load-test: poetry run locust",
+				"    prod: poetry run gunicorn some_app:app --workers 4",
+				"      Select a profile at launch with BPL_POETRY_RUN_PROFILE",
+			))
+		})
+	})
+
+	context("when the Python runtime can be found", func() {
+		var venvsDir string
+
+		it.Before(func() {
+			var err error
+			venvsDir, err = os.MkdirTemp("", "venvs")
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, name := range []string{"some-app-AbCdEf12-py3.11", "other-app-12345678-py3.12"} {
+				Expect(os.MkdirAll(filepath.Join(venvsDir, name), os.ModePerm)).To(Succeed())
+			}
+			Expect(os.WriteFile(filepath.Join(venvsDir, "some-app-AbCdEf12-py3.11", "pyvenv.cfg"), []byte("home = /layers/cpython/bin\nversion_info = 3.11.9.final.0\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(venvsDir, "other-app-12345678-py3.12", "pyvenv.cfg"), []byte("home = /layers/cpython/bin\nversion = 3.12.1\n"), 0644)).To(Succeed())
+
+			pyProjectParser.ParseCall.Returns.PyProject.Name = "Some_App"
+			Expect(os.Setenv("POETRY_VIRTUALENVS_PATH", venvsDir)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("POETRY_VIRTUALENVS_PATH")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_PREFLIGHT")).To(Succeed())
+			Expect(os.RemoveAll(venvsDir)).To(Succeed())
+		})
+
+		it("records the CPython version and the Python of the venv from its pyvenv.cfg in the image labels", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Labels).To(Equal(map[string]string{
+				"io.paketo.poetry-run.cpython-version":     "3.11.9",
+				"io.paketo.poetry-run.venv-python-version": "3.11.9",
+			}))
+
+			venv := filepath.Join(venvsDir, "some-app-AbCdEf12-py3.11")
+			Expect(buffer.String()).To(ContainLines(
+				"  Recording the Python runtime",
+				fmt.Sprintf("    Venv %s created with CPython 3.11.9", venv),
+				"      Recorded in the image labels io.paketo.poetry-run.cpython-version and io.paketo.poetry-run.venv-python-version",
+			))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("runtime.venv = %s", venv)))
+			Expect(buffer.String()).To(ContainSubstring("runtime.venv-python-version = 3.11.9"))
+		})
+
+		context("when BP_POETRY_RUN_PREFLIGHT is true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_PREFLIGHT", "true")).To(Succeed())
+			})
+
+			it("checks the Python of the venv at launch", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].Name).To(Equal("preflight"))
+				Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("POETRY_RUN_PREFLIGHT_PYTHON_VERSION.default", "3.11.9"))
+				Expect(buffer.String()).To(ContainSubstring("Checking that the venv still uses Python 3.11.9"))
+			})
+		})
+	})
+
+	context("when BP_POETRY_RUN_PREFLIGHT is true", func() {
+		it.Before(func() {
+			pyProjectParser.ParseCall.Returns.PyProject.Name = "some-app"
+			Expect(os.Setenv("BP_POETRY_RUN_PREFLIGHT", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_PREFLIGHT")).To(Succeed())
+			Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+		})
+
+		it("adds a launch layer with an exec.d helper that checks the venv and the script", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(2))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("preflight"))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Cache).To(BeFalse())
+			Expect(layer.LaunchEnv).To(Equal(packit.Environment{
+				"POETRY_RUN_PREFLIGHT_PROJECT.default": "some-app",
+				"POETRY_RUN_PREFLIGHT_SCRIPT.default":  "some-script",
+			}))
+			Expect(layer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "preflight")}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring the launch preflight check",
+				"    Checking that the venv exists and matches the CPython layer",
+				"    Checking that script some-script is installed",
+				"      Disable it at launch with BPL_POETRY_RUN_PREFLIGHT=false",
+			))
+		})
+
+		context("when the target is not a script", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn app:app")).To(Succeed())
+			})
+
+			it("only checks the venv", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].LaunchEnv).To(Equal(packit.Environment{
+					"POETRY_RUN_PREFLIGHT_PROJECT.default": "some-app",
+				}))
+				Expect(buffer.String()).NotTo(ContainSubstring("Checking that script"))
+			})
+		})
+	})
+
+	context("when BP_POETRY_RUN_DOTENV_ENABLED is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_POETRY_RUN_DOTENV_ENABLED", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_POETRY_RUN_DOTENV_ENABLED")).To(Succeed())
+		})
+
+		it("adds a launch layer with an exec.d helper that loads .env", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(2))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("dotenv"))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Cache).To(BeFalse())
+			Expect(layer.LaunchEnv).To(Equal(packit.Environment{
+				"POETRY_RUN_DOTENV_FILES.default": filepath.Join(workingDir, ".env"),
+			}))
+			Expect(layer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "load-dotenv")}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring .env files",
+				fmt.Sprintf("    Loading %s at launch", filepath.Join(workingDir, ".env")),
+				"      Variables that are already set are never overwritten",
+			))
+		})
+
+		context("when BP_POETRY_RUN_DOTENV_FILES is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_DOTENV_FILES", ".env.production:/etc/app/.env")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_DOTENV_FILES")).To(Succeed())
+			})
+
+			it("loads the given files in order", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].LaunchEnv).To(Equal(packit.Environment{
+					"POETRY_RUN_DOTENV_FILES.default": filepath.Join(workingDir, ".env.production") + ":/etc/app/.env",
+				}))
+			})
+		})
+	})
+
+	context("when poetry.toml conflicts with the venv", func() {
+		it.Before(func() {
+			inProject := true
+			path := "/some/venvs"
+			configFileParser.ParseCall.Returns.PoetryConfig.Virtualenvs.InProject = &inProject
+			configFileParser.ParseCall.Returns.PoetryConfig.Virtualenvs.Path = &path
+		})
+
+		it("overrides the settings it can at launch and warns about all of them", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(configFileParser.ParseCall.Receives.String).To(Equal(filepath.Join(workingDir, "poetry.toml")))
+
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("poetry-config"))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.LaunchEnv).To(Equal(packit.Environment{
+				"POETRY_VIRTUALENVS_IN_PROJECT.override": "false",
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Configuring launch environment",
+				`    POETRY_VIRTUALENVS_IN_PROJECT -> "false"`,
+			))
+			Expect(buffer.String()).To(ContainSubstring("Warning: poetry.toml sets virtualenvs.in-project = true, but the venv is not built in the project: overriding it with POETRY_VIRTUALENVS_IN_PROJECT=false at launch"))
+			Expect(buffer.String()).To(ContainSubstring(`Warning: poetry.toml sets virtualenvs.path = "/some/venvs", which may not hold the venv built by the poetry-install buildpack: remove it from poetry.toml`))
+		})
+
+		context("when none of the settings can be overridden", func() {
+			it.Before(func() {
+				configFileParser.ParseCall.Returns.PoetryConfig.Virtualenvs.InProject = nil
+			})
+
+			it("only warns", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(buffer.String()).To(ContainSubstring("Warning: poetry.toml sets virtualenvs.path"))
+			})
+		})
+	})
+
+	context("when a health check is configured", func() {
+		context("with a target", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health.Target = "python -m some_app.health"
+			})
+
+			it("adds a non-default 'poetry run' health process", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(Equal([]packit.Layer{reportLayer}))
+				Expect(result.Launch.DirectProcesses).To(Equal([]packit.DirectProcess{
+					{
+						Type:    "web",
+						Command: []string{"poetry", "run", "some-script"},
+						Default: true,
+					},
+					{
+						Type:    "health",
+						Command: []string{"poetry", "run", "python", "-m", "some_app.health"},
+					},
+				}))
+			})
+		})
+
+		context("with an HTTP path", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(cnbDir, "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(cnbDir, "bin", "health"), []byte("health-binary"), 0755)).To(Succeed())
+
+				Expect(os.Setenv("BP_POETRY_RUN_HEALTH_HTTP_PATH", "/healthz")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_HEALTH_HTTP_PATH")).To(Succeed())
+			})
+
+			it("installs the health check into a launch layer and adds a non-default health process", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(2))
+				layer := result.Layers[0]
+				Expect(layer.Name).To(Equal("health"))
+				Expect(layer.Launch).To(BeTrue())
+				Expect(layer.Build).To(BeFalse())
+				Expect(layer.Cache).To(BeFalse())
+
+				binary := filepath.Join(layersDir, "health", "bin", "health")
+				Expect(binary).To(BeARegularFile())
+
+				Expect(result.Launch.DirectProcesses).To(ContainElement(packit.DirectProcess{
+					Type:    "health",
+					Command: []string{binary, "--http", "/healthz"},
+				}))
+
+				Expect(buffer.String()).To(ContainLines(
+					"  Configuring the health process",
+					"    Checking HTTP GET /healthz on $PORT",
+				))
+			})
+		})
+
+		context("with a TCP port", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(cnbDir, "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(cnbDir, "bin", "health"), []byte("health-binary"), 0755)).To(Succeed())
+
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health.TCPPort = 5432
+			})
+
+			it("checks the port", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.DirectProcesses).To(ContainElement(packit.DirectProcess{
+					Type:    "health",
+					Command: []string{filepath.Join(layersDir, "health", "bin", "health"), "--tcp", "5432"},
+				}))
+			})
+		})
+	})
+
+	context("build report", func() {
+		it.Before(func() {
+			reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+				"release": "alembic upgrade head",
+			}
+			pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{
+				"dev": "python manage.py runserver",
+			}
+		})
+
+		it("writes the resolved target and processes to a launch layer and the build log", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			layer := result.Layers[len(result.Layers)-1]
+			Expect(layer.Name).To(Equal("poetry-run"))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Cache).To(BeFalse())
+
+			var report poetryrun.Report
+			_, err = toml.DecodeFile(filepath.Join(layersDir, "poetry-run", "report.toml"), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			var jsonReport poetryrun.Report
+			content, err := os.ReadFile(filepath.Join(layersDir, "poetry-run", "report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(content, &jsonReport)).To(Succeed())
+			Expect(jsonReport).To(Equal(report))
+
+			Expect(report).To(Equal(poetryrun.Report{
+				Target: poetryrun.ReportTarget{
+					Kind:        poetryrun.ScriptTarget,
+					Command:     []string{"poetry", "run", "some-script"},
+					Source:      poetryrun.ScriptSource,
+					Explanation: "Found pyproject.toml script=some-script",
+				},
+				Processes: []poetryrun.ReportProcess{
+					{
+						Type:    "reload-web",
+						Command: []string{"watchexec", "--restart", "--watch", workingDir, "--shell", "none", "--", "bash", "-c", `set -f; exec poetry run $POETRY_RUN_TARGET "$@"`, "web"},
+						Default: true,
+						Source:  poetryrun.EnvironmentSource,
+					},
+					{
+						Type:    "web",
+						Command: []string{"bash", "-c", `set -f; exec poetry run $POETRY_RUN_TARGET "$@"`, "web"},
+						Source:  poetryrun.ScriptSource,
+					},
+					{
+						Type:    "release",
+						Command: []string{"poetry", "run", "alembic"},
+						Args:    []string{"upgrade", "head"},
+						Source:  poetryrun.ConfigurationSource,
+					},
+				},
+				Profiles: []poetryrun.ReportProfile{
+					{
+						Name:    "dev",
+						Command: []string{"poetry", "run", "python", "manage.py", "runserver"},
+						Source:  poetryrun.ConfigurationSource,
+					},
+				},
+				Reload: poetryrun.ReportReload{
+					Enabled:    true,
+					Strategy:   poetryrun.WatchexecStrategy,
+					WatchPaths: []string{workingDir},
+				},
+				Warnings: []string{},
+			}))
+
+			Expect(buffer.String()).To(ContainLines(
+				"  Build report",
+				"    target.command = poetry run some-script",
+				"    target.kind = script",
+				"    target.source = single-script",
+			))
+			Expect(buffer.String()).To(ContainLines(
+				"    process.release = poetry run alembic upgrade head",
+				"    process.release.default = false",
+				"    process.release.source = pyproject-config",
+				"    profile.dev = poetry run python manage.py runserver",
+				"    profile.dev.source = pyproject-config",
+				"    reload.enabled = true",
+				"    reload.strategy = watchexec",
+				fmt.Sprintf("    reload.watch-paths = %s", workingDir),
+			))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_POETRY_RUN_TARGET is not set", func() {
+			it.Before(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_TARGET")).To(Succeed())
+			})
+
+			context(" and the pyproject.toml parser returns an error", func() {
+				it.Before(func() {
+					pyProjectParser.ParseCall.Returns.Error = fmt.Errorf("some error")
+				})
+
+				it("returns the error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("some error")))
+				})
+			})
+
+			context(" and pyproject.toml is not valid TOML", func() {
+				it.Before(func() {
+					pyProjectParser.ParseCall.Returns.Error = poetryrun.DetectionError{
+						Reason: poetryrun.InvalidTOML,
+						Path:   "some-path",
+						Line:   3,
+						Column: 7,
+						Err:    errors.New("expected a value"),
+					}
+				})
+
+				it("returns the error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("expected a value")))
+				})
+			})
+		})
+
+		context("when BP_POETRY_RUN_TARGET is not set and pyproject.toml does not define exactly one script", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.Scripts = map[string]poetryrun.Script{}
+			})
+
+			it("returns a detection error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(poetryrun.DetectionError{
+					Reason: poetryrun.NoScripts,
+					Path:   filepath.Join(workingDir, "pyproject.toml"),
+				}))
+			})
+		})
+
+		context("when a profile name is invalid", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{
+					"my profile": "gunicorn app:app",
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid profile "my profile": profile names may only contain letters, numbers, '-' and '_'`))
+			})
+		})
+
+		context("when a profile is named default", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_TARGET_DEFAULT", "gunicorn app:app")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_TARGET_DEFAULT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid profile "default": the default profile runs the resolved run target`))
+			})
+		})
+
+		context("when a profile has no target", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{
+					"dev": "",
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid profile "dev": run target must not be empty`))
+			})
+		})
+
+		context("when more than one health check is configured", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health = poetryrun.HealthConfig{
+					Target:   "healthcheck",
+					HTTPPath: "/healthz",
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid health check: only one of a target, an HTTP path or a TCP port may be set, found a target and an HTTP path"))
+			})
+		})
+
+		context("when the health check HTTP path is relative", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health.HTTPPath = "healthz"
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid health check: HTTP path "healthz" must start with '/'`))
+			})
+		})
+
+		context("when BP_POETRY_RUN_HEALTH_TCP_PORT is not a port", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_HEALTH_TCP_PORT", "70000")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_HEALTH_TCP_PORT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid health check: TCP port 70000 must be between 1 and 65535"))
+			})
+		})
+
+		context("when the health check binary cannot be installed", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_HEALTH_HTTP_PATH", "/healthz")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_HEALTH_HTTP_PATH")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to install the health check")))
+			})
+		})
+
+		context("when a profile target references an undefined variable", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Profiles = map[string]string{
+					"prod": "gunicorn ${APP_MODULE}:app",
+				}
+				Expect(os.Setenv("BP_POETRY_RUN_EXPAND_ENV", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_EXPAND_ENV")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed to expand the target of profile "prod": undefined variable APP_MODULE: set it at build time or give a default with ${APP_MODULE:-default}`))
+			})
+		})
+
+		context("when process settings name an unknown process type", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessSettings = map[string]poetryrun.ProcessSettings{
+					"worker": {Env: map[string]string{"SOME_VAR": "some-value"}},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid process settings for "worker": there is no process of that type`))
+			})
+		})
+
+		context("when a process working directory is outside the app", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessSettings = map[string]poetryrun.ProcessSettings{
+					"web": {WorkingDirectory: "src/../.."},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid working directory "src/../.." for process "web": must be inside the app directory`))
+			})
+		})
+
+		context("when a process working directory links outside the app", func() {
+			var outside string
+
+			it.Before(func() {
+				var err error
+				outside, err = os.MkdirTemp("", "outside")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Symlink(outside, filepath.Join(workingDir, "src"))).To(Succeed())
+
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessSettings = map[string]poetryrun.ProcessSettings{
+					"web": {WorkingDirectory: "src"},
+				}
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(outside)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid working directory "src" for process "web": must be inside the app directory`))
+			})
+		})
+
+		context("when a process working directory does not exist", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessSettings = map[string]poetryrun.ProcessSettings{
+					"web": {WorkingDirectory: "src"},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid working directory "src" for process "web": no such directory in the app`))
+			})
+		})
+
+		context("when a process environment variable name is invalid", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.ProcessSettings = map[string]poetryrun.ProcessSettings{
+					"web": {Env: map[string]string{"SOME-VAR": "some-value"}},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid environment variable "SOME-VAR" for process "web": variable names may only contain letters, numbers and '_'`))
+			})
+		})
+
+		context("when a poetry global option is not supported", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_POETRY_OPTIONS", "--no-plugins --help")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_POETRY_OPTIONS")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid BP_POETRY_RUN_POETRY_OPTIONS value --no-plugins --help: --help is not a supported global option of poetry, use --quiet, --verbose, --ansi, --no-ansi, --no-interaction, --no-plugins, --no-cache, --directory or --project"))
+			})
+		})
+
+		context("when a poetry global option is missing its value", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.PoetryOptions = "--directory --quiet"
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid [tool.paketo.poetry-run] poetry-options value --directory --quiet: --directory requires a value"))
+			})
+		})
+
+		context("when a poetry global flag is given a value", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.PoetryOptions = "--quiet=true"
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid [tool.paketo.poetry-run] poetry-options value --quiet=true: --quiet does not take a value"))
+			})
+		})
+
+		context("when an auxiliary process is named health", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Health.Target = "healthcheck"
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+					"health": "python -m some_app.health",
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid process type "health": conflicts with a process assigned by this buildpack`))
+			})
+		})
+
+		context("when an auxiliary process type is invalid", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+					"my release": "alembic upgrade head",
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid process type "my release": process types may only contain letters, numbers, '.', '_' and '-'`))
+			})
+		})
+
+		context("when an auxiliary process conflicts with an assigned process", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject.PoetryRun.Processes = map[string]string{
+					"web": "gunicorn app:app",
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid process type "web": conflicts with a process assigned by this buildpack`))
+			})
+		})
+
+		context("when an auxiliary process has no target", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_PROCESS_RELEASE", " ")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_PROCESS_RELEASE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid process "release": run target must not be empty`))
+			})
+		})
+
+		context("when BP_POETRY_RUN_DOTENV_ENABLED is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_DOTENV_ENABLED", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_DOTENV_ENABLED")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_POETRY_RUN_DOTENV_ENABLED value not-a-bool")))
+			})
+		})
+
+		context("when BP_POETRY_RUN_PREFLIGHT is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_PREFLIGHT", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_PREFLIGHT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_POETRY_RUN_PREFLIGHT value not-a-bool")))
+			})
+		})
+
+		context("when BP_POETRY_RUN_INFER_WORKERS is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_INFER_WORKERS", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_INFER_WORKERS")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_POETRY_RUN_INFER_WORKERS value not-a-bool")))
+			})
+		})
+
+		context("when a graceful restart setting is invalid", func() {
+			it.Before(func() {
+				reloader.ShouldEnableLiveReloadCall.Returns.Bool = true
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_LIVE_RELOAD_STOP_SIGNAL")).To(Succeed())
+				Expect(os.Unsetenv("BP_LIVE_RELOAD_STOP_TIMEOUT")).To(Succeed())
+				Expect(os.Unsetenv("BP_LIVE_RELOAD_ON_CHANGE")).To(Succeed())
+			})
+
+			it("returns an error for a signal that is not a signal name", func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_STOP_SIGNAL", "15; rm")).To(Succeed())
+
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid BP_LIVE_RELOAD_STOP_SIGNAL value 15; rm: must be a signal name, e.g. SIGTERM"))
+			})
+
+			it("returns an error for a timeout that is not a duration", func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_STOP_TIMEOUT", "ten")).To(Succeed())
+
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid BP_LIVE_RELOAD_STOP_TIMEOUT value ten: must be a duration, e.g. 500ms or 10s"))
+			})
+
+			it("returns an error for an unknown mode", func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_ON_CHANGE", "reload")).To(Succeed())
+
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid BP_LIVE_RELOAD_ON_CHANGE value reload: must be restart or signal"))
+			})
+		})
+
+		context("when poetry.toml cannot be parsed", func() {
+			it.Before(func() {
+				configFileParser.ParseCall.Returns.Error = errors.New("failed to parse poetry.toml")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed to parse poetry.toml"))
+			})
+		})
+
+		context("when BP_POETRY_RUN_LOCK_CHECK is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_LOCK_CHECK", "strict")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_LOCK_CHECK")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("invalid BP_POETRY_RUN_LOCK_CHECK value strict: must be one of warn, fail or off"))
+			})
+		})
+
+		context("when poetry.lock cannot be parsed", func() {
+			it.Before(func() {
+				lockFileParser.ParseCall.Returns.Error = errors.New("failed to parse poetry.lock")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed to parse poetry.lock"))
 			})
 		})
 
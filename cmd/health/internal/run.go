@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultPort is the port checked by --http when PORT is not set at launch.
+const defaultPort = "8080"
+
+// Run checks the health of the app as configured by args, which are either
+// '--http <path>', to send a GET request for <path> to the port named by
+// PORT, or '--tcp <port>', to open a TCP connection to <port>. Both connect
+// to the loopback interface. A check fails when it takes longer than
+// '--timeout' or, for HTTP, when the response status is not 2xx or 3xx.
+func Run(args []string, environment map[string]string, output io.Writer) error {
+	flags := flag.NewFlagSet("health", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	path := flags.String("http", "", "path to send an HTTP GET request to on PORT")
+	port := flags.String("tcp", "", "TCP port to connect to")
+	timeout := flags.Duration("timeout", 3*time.Second, "timeout of the check")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse arguments: %w", err)
+	}
+
+	switch {
+	case *path != "" && *port != "":
+		return errors.New("failed to parse arguments: only one of --http and --tcp may be set")
+
+	case *path != "":
+		httpPort := environment["PORT"]
+		if httpPort == "" {
+			httpPort = defaultPort
+		}
+
+		url := fmt.Sprintf("http://%s%s", net.JoinHostPort("127.0.0.1", httpPort), *path)
+
+		client := http.Client{
+			Timeout: *timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		response, err := client.Get(url)
+		if err != nil {
+			return fmt.Errorf("unhealthy: %w", err)
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode < 200 || response.StatusCode >= 400 {
+			return fmt.Errorf("unhealthy: GET %s returned %s", url, response.Status)
+		}
+
+		fmt.Fprintf(output, "healthy: GET %s returned %s\n", url, response.Status)
+
+	case *port != "":
+		address := net.JoinHostPort("127.0.0.1", *port)
+
+		connection, err := net.DialTimeout("tcp", address, *timeout)
+		if err != nil {
+			return fmt.Errorf("unhealthy: %w", err)
+		}
+		defer connection.Close()
+
+		fmt.Fprintf(output, "healthy: connected to %s\n", address)
+
+	default:
+		return errors.New("failed to parse arguments: one of --http or --tcp must be set")
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package internal_test
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/paketo-buildpacks/poetry-run/cmd/health/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		output *bytes.Buffer
+	)
+
+	it.Before(func() {
+		output = bytes.NewBuffer(nil)
+	})
+
+	context("--http", func() {
+		var (
+			server *httptest.Server
+			port   string
+		)
+
+		it.Before(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/healthz":
+					w.WriteHeader(http.StatusOK)
+				default:
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+
+			var err error
+			_, port, err = net.SplitHostPort(server.Listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("succeeds when the path responds with a 2xx status", func() {
+			err := internal.Run([]string{"--http", "/healthz"}, map[string]string{"PORT": port}, output)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output.String()).To(Equal("healthy: GET http://127.0.0.1:" + port + "/healthz returned 200 OK\n"))
+		})
+
+		it("fails when the path responds with an error status", func() {
+			err := internal.Run([]string{"--http", "/ready"}, map[string]string{"PORT": port}, output)
+			Expect(err).To(MatchError("unhealthy: GET http://127.0.0.1:" + port + "/ready returned 503 Service Unavailable"))
+		})
+	})
+
+	context("--tcp", func() {
+		var listener net.Listener
+
+		it.Before(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("succeeds when the port accepts connections", func() {
+			_, port, err := net.SplitHostPort(listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+
+			err = internal.Run([]string{"--tcp", port}, map[string]string{}, output)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output.String()).To(Equal("healthy: connected to 127.0.0.1:" + port + "\n"))
+
+			Expect(listener.Close()).To(Succeed())
+		})
+
+		it("fails when nothing listens on the port", func() {
+			_, port, err := net.SplitHostPort(listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listener.Close()).To(Succeed())
+
+			err = internal.Run([]string{"--tcp", port}, map[string]string{}, output)
+			Expect(err).To(MatchError(ContainSubstring("unhealthy: dial tcp 127.0.0.1:" + port)))
+		})
+	})
+
+	context("failure cases", func() {
+		it("returns an error when no check is given", func() {
+			err := internal.Run(nil, map[string]string{}, output)
+			Expect(err).To(MatchError("failed to parse arguments: one of --http or --tcp must be set"))
+		})
+
+		it("returns an error when both checks are given", func() {
+			err := internal.Run([]string{"--http", "/", "--tcp", "5432"}, map[string]string{}, output)
+			Expect(err).To(MatchError("failed to parse arguments: only one of --http and --tcp may be set"))
+		})
+
+		it("returns an error for an unknown flag", func() {
+			err := internal.Run([]string{"--udp", "53"}, map[string]string{}, output)
+			Expect(err).To(MatchError(ContainSubstring("failed to parse arguments: flag provided but not defined: -udp")))
+		})
+	})
+}
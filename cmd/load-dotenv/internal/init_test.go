@@ -0,0 +1,15 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitLoadDotenv(t *testing.T) {
+	suite := spec.New("cmd/load-dotenv/internal", spec.Report(report.Terminal{}))
+	suite("Parse", testParse)
+	suite("Run", testRun)
+	suite.Run(t)
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Variable is a variable defined in a .env file.
+type Variable struct {
+	Name  string
+	Value string
+}
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Parse parses the contents of a .env file. It supports blank lines, '#'
+// comments, an optional 'export' prefix, unquoted values with trailing
+// comments, single-quoted literal values and double-quoted values with
+// escape sequences. Quoted values may span multiple lines. Variables are not
+// interpolated.
+//
+// Errors never contain values, since those are usually secrets.
+func Parse(contents string) ([]Variable, error) {
+	var variables []Variable
+
+	p := parser{input: contents, line: 1}
+	for {
+		p.skipBlankAndComments()
+		if p.done() {
+			return variables, nil
+		}
+
+		line := p.line
+		variable, err := p.variable()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		variables = append(variables, variable)
+	}
+}
+
+type parser struct {
+	input string
+	pos   int
+	line  int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.input[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) skipSpaces() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipLine skips the rest of the current line, including the newline.
+func (p *parser) skipLine() {
+	for !p.done() && p.next() != '\n' {
+	}
+}
+
+func (p *parser) skipBlankAndComments() {
+	for !p.done() {
+		p.skipSpaces()
+		if p.done() {
+			return
+		}
+
+		switch p.peek() {
+		case '\n', '\r', '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) variable() (Variable, error) {
+	end := strings.IndexByte(p.input[p.pos:], '=')
+	if newline := strings.IndexByte(p.input[p.pos:], '\n'); end < 0 || (newline >= 0 && newline < end) {
+		return Variable{}, fmt.Errorf("expected NAME=VALUE")
+	}
+
+	name := strings.TrimSpace(p.input[p.pos : p.pos+end])
+	if rest, found := strings.CutPrefix(name, "export"); found && (strings.HasPrefix(rest, " ") || strings.HasPrefix(rest, "\t")) {
+		name = strings.TrimSpace(rest)
+	}
+
+	if !variableName.MatchString(name) {
+		return Variable{}, fmt.Errorf("invalid variable name %q", name)
+	}
+
+	p.pos += end + 1
+	p.skipSpaces()
+
+	value, err := p.value()
+	if err != nil {
+		return Variable{}, fmt.Errorf("variable %s: %w", name, err)
+	}
+
+	return Variable{Name: name, Value: value}, nil
+}
+
+func (p *parser) value() (string, error) {
+	if p.done() {
+		return "", nil
+	}
+
+	var value string
+	switch p.peek() {
+	case '\'':
+		p.next()
+		end := strings.IndexByte(p.input[p.pos:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+
+		value = p.input[p.pos : p.pos+end]
+		for i := 0; i <= end; i++ {
+			p.next()
+		}
+
+	case '"':
+		p.next()
+		var builder strings.Builder
+		for {
+			if p.done() {
+				return "", fmt.Errorf("unterminated double-quoted value")
+			}
+
+			c := p.next()
+			if c == '"' {
+				break
+			}
+
+			if c == '\\' && !p.done() {
+				escaped := p.next()
+				switch escaped {
+				case 'n':
+					builder.WriteByte('\n')
+				case 'r':
+					builder.WriteByte('\r')
+				case 't':
+					builder.WriteByte('\t')
+				case '"', '\\', '$':
+					builder.WriteByte(escaped)
+				default:
+					builder.WriteByte('\\')
+					builder.WriteByte(escaped)
+				}
+				continue
+			}
+
+			builder.WriteByte(c)
+		}
+		value = builder.String()
+
+	default:
+		end := strings.IndexByte(p.input[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.input) - p.pos
+		}
+
+		raw := p.input[p.pos : p.pos+end]
+		for i, c := range raw {
+			if c == '#' && (i == 0 || raw[i-1] == ' ' || raw[i-1] == '\t') {
+				raw = raw[:i]
+				break
+			}
+		}
+		p.skipLine()
+
+		return strings.TrimSpace(raw), nil
+	}
+
+	// Only whitespace and a comment may follow a quoted value.
+	p.skipSpaces()
+	if !p.done() && p.peek() != '\n' && p.peek() != '\r' && p.peek() != '#' {
+		return "", fmt.Errorf("unexpected characters after quoted value")
+	}
+	p.skipLine()
+
+	return value, nil
+}
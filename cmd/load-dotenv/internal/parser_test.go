@@ -0,0 +1,66 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/poetry-run/cmd/load-dotenv/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testParse(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	it("parses variables with quoting, comments and export prefixes", func() {
+		variables, err := internal.Parse(`# database settings
+DATABASE_URL=postgres://localhost/app
+export SECRET_KEY = 'it is # not a comment'
+
+  DEBUG=true # enable debugging
+EMPTY=
+HASH=abc#def
+GREETING="hello\n\"world\"" # a comment
+MULTILINE="first
+second"
+SINGLE='no \n escapes'
+`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(variables).To(Equal([]internal.Variable{
+			{Name: "DATABASE_URL", Value: "postgres://localhost/app"},
+			{Name: "SECRET_KEY", Value: "it is # not a comment"},
+			{Name: "DEBUG", Value: "true"},
+			{Name: "EMPTY", Value: ""},
+			{Name: "HASH", Value: "abc#def"},
+			{Name: "GREETING", Value: "hello\n\"world\""},
+			{Name: "MULTILINE", Value: "first\nsecond"},
+			{Name: "SINGLE", Value: `no \n escapes`},
+		}))
+	})
+
+	context("failure cases", func() {
+		it("returns an error for a line without an assignment", func() {
+			_, err := internal.Parse("FOO=bar\nnot an assignment\n")
+			Expect(err).To(MatchError("line 2: expected NAME=VALUE"))
+		})
+
+		it("returns an error for an invalid variable name", func() {
+			_, err := internal.Parse("1FOO=bar\n")
+			Expect(err).To(MatchError(`line 1: invalid variable name "1FOO"`))
+		})
+
+		it("returns an error for an unterminated quoted value without the value", func() {
+			_, err := internal.Parse("FOO=\"secret\nBAR=baz\n")
+			Expect(err).To(MatchError("line 1: variable FOO: unterminated double-quoted value"))
+
+			_, err = internal.Parse("FOO='secret\n")
+			Expect(err).To(MatchError("line 1: variable FOO: unterminated single-quoted value"))
+		})
+
+		it("returns an error for characters after a quoted value", func() {
+			_, err := internal.Parse(`FOO="secret"trailing`)
+			Expect(err).To(MatchError("line 1: variable FOO: unexpected characters after quoted value"))
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// FilesEnv lists the .env files baked in at build time, separated by ':'.
+	FilesEnv = "POETRY_RUN_DOTENV_FILES"
+
+	// FilesOverrideEnv replaces the .env files baked in at build time. Relative
+	// paths are resolved against the working directory.
+	FilesOverrideEnv = "BPL_POETRY_RUN_DOTENV_FILES"
+)
+
+// Run loads the variables defined in the configured .env files and writes
+// them to output, in the TOML format that exec.d expects. Files that do not
+// exist are skipped. A variable that is already set in the given environment
+// is never overwritten, and the first file to define a variable wins.
+//
+// Every loaded or skipped variable is reported to logs with its value
+// redacted.
+func Run(environment map[string]string, workingDir string, output, logs io.Writer) error {
+	files := environment[FilesEnv]
+	if override, ok := environment[FilesOverrideEnv]; ok {
+		files = override
+	}
+
+	loaded := map[string]string{}
+	for _, file := range filepath.SplitList(files) {
+		if file == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workingDir, file)
+		}
+
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		variables, err := Parse(string(contents))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+
+		for _, variable := range variables {
+			if _, ok := environment[variable.Name]; ok {
+				fmt.Fprintf(logs, "Skipped %s from %s: already set\n", variable.Name, file)
+				continue
+			}
+
+			if _, ok := loaded[variable.Name]; ok {
+				fmt.Fprintf(logs, "Skipped %s from %s: already loaded from another file\n", variable.Name, file)
+				continue
+			}
+
+			loaded[variable.Name] = variable.Value
+			fmt.Fprintf(logs, "Loaded %s=%s from %s\n", variable.Name, redact(variable.Value), file)
+		}
+	}
+
+	if len(loaded) == 0 {
+		return nil
+	}
+
+	err := toml.NewEncoder(output).Encode(loaded)
+	if err != nil {
+		return fmt.Errorf("failed to write environment: %w", err)
+	}
+
+	return nil
+}
+
+func redact(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	return "********"
+}
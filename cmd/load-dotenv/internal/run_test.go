@@ -0,0 +1,95 @@
+package internal_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/poetry-run/cmd/load-dotenv/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir  string
+		environment map[string]string
+		output      *bytes.Buffer
+		logs        *bytes.Buffer
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, ".env.local"), []byte("SECRET_KEY=local-secret\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, ".env"), []byte("SECRET_KEY=shared-secret\nPORT=9000\nDEBUG=true\n"), 0600)).To(Succeed())
+
+		environment = map[string]string{
+			"POETRY_RUN_DOTENV_FILES": filepath.Join(workingDir, ".env.local") + ":" + filepath.Join(workingDir, ".env"),
+			"PORT":                    "8080",
+		}
+		output = bytes.NewBuffer(nil)
+		logs = bytes.NewBuffer(nil)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	it("loads the variables that are not already set and redacts their values", func() {
+		Expect(internal.Run(environment, workingDir, output, logs)).To(Succeed())
+
+		Expect(output.String()).To(Equal("DEBUG = \"true\"\nSECRET_KEY = \"local-secret\"\n"))
+
+		Expect(logs.String()).To(Equal(
+			"Loaded SECRET_KEY=******** from " + filepath.Join(workingDir, ".env.local") + "\n" +
+				"Skipped SECRET_KEY from " + filepath.Join(workingDir, ".env") + ": already loaded from another file\n" +
+				"Skipped PORT from " + filepath.Join(workingDir, ".env") + ": already set\n" +
+				"Loaded DEBUG=******** from " + filepath.Join(workingDir, ".env") + "\n",
+		))
+		Expect(logs.String()).NotTo(ContainSubstring("secret"))
+	})
+
+	context("when BPL_POETRY_RUN_DOTENV_FILES is set", func() {
+		it.Before(func() {
+			environment["BPL_POETRY_RUN_DOTENV_FILES"] = ".env"
+		})
+
+		it("loads the given files relative to the working directory instead", func() {
+			Expect(internal.Run(environment, workingDir, output, logs)).To(Succeed())
+			Expect(output.String()).To(Equal("DEBUG = \"true\"\nSECRET_KEY = \"shared-secret\"\n"))
+		})
+	})
+
+	context("when a file does not exist", func() {
+		it.Before(func() {
+			environment["BPL_POETRY_RUN_DOTENV_FILES"] = ".env.missing"
+		})
+
+		it("skips it", func() {
+			Expect(internal.Run(environment, workingDir, output, logs)).To(Succeed())
+			Expect(output.String()).To(BeEmpty())
+			Expect(logs.String()).To(BeEmpty())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when a file cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".env"), []byte("SECRET_KEY=\"unterminated-secret\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error that does not contain the value", func() {
+				err := internal.Run(environment, workingDir, output, logs)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse " + filepath.Join(workingDir, ".env") + ": line 1: variable SECRET_KEY: unterminated double-quoted value")))
+				Expect(err.Error()).NotTo(ContainSubstring("unterminated-secret"))
+			})
+		})
+	})
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+)
+
+const (
+	// ScriptEnv names the console script that the default process runs. It
+	// is set at build time when the target is a script.
+	ScriptEnv = "POETRY_RUN_PREFLIGHT_SCRIPT"
+
+	// ProjectEnv holds the name of the project, which Poetry uses as the
+	// prefix of the venv directory.
+	ProjectEnv = "POETRY_RUN_PREFLIGHT_PROJECT"
+
+	// PythonVersionEnv holds the Python version the venv was created with at
+	// build time, e.g. "3.11.9".
+	PythonVersionEnv = "POETRY_RUN_PREFLIGHT_PYTHON_VERSION"
+
+	// EnabledEnv disables the preflight check at launch when set to false.
+	EnabledEnv = "BPL_POETRY_RUN_PREFLIGHT"
+
+	// ProfileEnv names the run profile selected at launch. The script check
+	// is skipped when a profile other than the default is selected.
+	ProfileEnv = "BPL_POETRY_RUN_PROFILE"
+)
+
+// Run checks that the venv of the app can run the target before the process
+// starts:
+//
+//   - the venv exists, in $VIRTUAL_ENV, <workingDir>/.venv or under
+//     $POETRY_VIRTUALENVS_PATH;
+//   - its interpreter exists;
+//   - it uses the major.minor Python version recorded at build time in
+//     POETRY_RUN_PREFLIGHT_PYTHON_VERSION;
+//   - it was built against the CPython found on $PATH, in the same
+//     major.minor version;
+//   - the console script named by POETRY_RUN_PREFLIGHT_SCRIPT is installed.
+//
+// It returns an error describing the first failed check.
+func Run(environment map[string]string, workingDir string) error {
+	if strings.EqualFold(environment[EnabledEnv], "false") {
+		return nil
+	}
+
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := environment[name]
+		return value, ok
+	}
+
+	venv, err := poetryrun.FindVenv(lookupEnv, workingDir, environment[ProjectEnv])
+	if err != nil {
+		return err
+	}
+
+	config, err := poetryrun.ReadVenvConfig(venv)
+	if err != nil {
+		return err
+	}
+
+	interpreter := filepath.Join(venv, "bin", "python")
+	if _, err := os.Stat(interpreter); err != nil {
+		return fmt.Errorf("the venv interpreter %s is missing or points to a missing file: rebuild the image", interpreter)
+	}
+
+	version := majorMinor(config.Version)
+	if version == "" {
+		return fmt.Errorf("failed to read the Python version of the venv from %s", filepath.Join(venv, "pyvenv.cfg"))
+	}
+
+	if recorded := majorMinor(environment[PythonVersionEnv]); recorded != "" && recorded != version {
+		return fmt.Errorf("the venv in %s uses Python %s, but the image was built with Python %s: rebuild the image", venv, version, recorded)
+	}
+
+	home := config.Home
+	if _, err := os.Stat(filepath.Join(home, "python"+version)); err != nil {
+		return fmt.Errorf("the venv in %s was built against Python %s in %s, which is no longer available: rebuild the image", venv, version, home)
+	}
+
+	python, ok := lookPath(environment["PATH"], "python3")
+	if !ok {
+		return fmt.Errorf("failed to find python3 on PATH: the CPython layer is missing")
+	}
+
+	// python3 may be a link into the CPython layer, e.g. from the bin
+	// directory of the venv.
+	if resolved, err := filepath.EvalSymlinks(python); err == nil {
+		python = resolved
+	}
+
+	if !sameDir(filepath.Dir(python), home) {
+		return fmt.Errorf("the venv in %s was built against the Python in %s, but python3 on PATH is %s: rebuild the image", venv, home, python)
+	}
+
+	script := environment[ScriptEnv]
+	profile := environment[ProfileEnv]
+	if script != "" && (profile == "" || profile == "default") {
+		info, err := os.Stat(filepath.Join(venv, "bin", script))
+		if err != nil || info.IsDir() {
+			return fmt.Errorf("the script %s is not installed in the venv in %s: check that the project is installed, e.g. that package-mode is not false", script, venv)
+		}
+	}
+
+	return nil
+}
+
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+func lookPath(path, name string) (string, bool) {
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+
+		file := filepath.Join(dir, name)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+func sameDir(a, b string) bool {
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+
+	return filepath.Clean(a) == filepath.Clean(b)
+}
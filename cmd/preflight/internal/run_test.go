@@ -0,0 +1,211 @@
+package internal_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/poetry-run/cmd/preflight/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		layersDir   string
+		workingDir  string
+		cpythonBin  string
+		venvsDir    string
+		venv        string
+		environment map[string]string
+	)
+
+	writeVenv := func(venv, version string) {
+		Expect(os.MkdirAll(filepath.Join(venv, "bin"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte(fmt.Sprintf("home = %s\ninclude-system-site-packages = false\nversion = %s\n", cpythonBin, version)), 0644)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(cpythonBin, "python3.11"), filepath.Join(venv, "bin", "python"))).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		layersDir, err = os.MkdirTemp("", "layers")
+		Expect(err).NotTo(HaveOccurred())
+
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		cpythonBin = filepath.Join(layersDir, "cpython", "bin")
+		Expect(os.MkdirAll(cpythonBin, os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(cpythonBin, "python3.11"), nil, 0755)).To(Succeed())
+		Expect(os.Symlink("python3.11", filepath.Join(cpythonBin, "python3"))).To(Succeed())
+
+		venvsDir = filepath.Join(layersDir, "poetry-venv")
+		venv = filepath.Join(venvsDir, "some-app-AbCdEf12-py3.11")
+		writeVenv(venv, "3.11.9")
+		Expect(os.WriteFile(filepath.Join(venv, "bin", "some-script"), nil, 0755)).To(Succeed())
+
+		environment = map[string]string{
+			"PATH":                         cpythonBin,
+			"POETRY_VIRTUALENVS_PATH":      venvsDir,
+			"POETRY_RUN_PREFLIGHT_PROJECT": "Some_App",
+			"POETRY_RUN_PREFLIGHT_SCRIPT":  "some-script",
+
+			"POETRY_RUN_PREFLIGHT_PYTHON_VERSION": "3.11.4",
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(layersDir)).To(Succeed())
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	it("passes when the venv matches the CPython layer and has the script", func() {
+		Expect(internal.Run(environment, workingDir)).To(Succeed())
+	})
+
+	context("when python3 on PATH links into the CPython layer", func() {
+		it.Before(func() {
+			Expect(os.Symlink(filepath.Join(cpythonBin, "python3"), filepath.Join(venv, "bin", "python3"))).To(Succeed())
+			environment["PATH"] = filepath.Join(venv, "bin") + ":" + cpythonBin
+		})
+
+		it("passes", func() {
+			Expect(internal.Run(environment, workingDir)).To(Succeed())
+		})
+	})
+
+	context("when there are several venvs", func() {
+		it.Before(func() {
+			writeVenv(filepath.Join(venvsDir, "other-app-12345678-py3.11"), "3.11.9")
+			Expect(os.RemoveAll(filepath.Join(venv, "bin", "some-script"))).To(Succeed())
+		})
+
+		it("checks the venv of the project", func() {
+			err := internal.Run(environment, workingDir)
+			Expect(err).To(MatchError(fmt.Sprintf("the script some-script is not installed in the venv in %s: check that the project is installed, e.g. that package-mode is not false", venv)))
+		})
+	})
+
+	context("when the app has an in-project venv", func() {
+		it.Before(func() {
+			writeVenv(filepath.Join(workingDir, ".venv"), "3.11.9")
+		})
+
+		it("checks that venv", func() {
+			err := internal.Run(environment, workingDir)
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("the script some-script is not installed in the venv in %s", filepath.Join(workingDir, ".venv")))))
+		})
+	})
+
+	context("when a run profile is selected", func() {
+		it.Before(func() {
+			environment["BPL_POETRY_RUN_PROFILE"] = "dev"
+			Expect(os.RemoveAll(filepath.Join(venv, "bin", "some-script"))).To(Succeed())
+		})
+
+		it("skips the script check", func() {
+			Expect(internal.Run(environment, workingDir)).To(Succeed())
+		})
+	})
+
+	context("when BPL_POETRY_RUN_PREFLIGHT is false", func() {
+		it.Before(func() {
+			environment["BPL_POETRY_RUN_PREFLIGHT"] = "false"
+			Expect(os.RemoveAll(venvsDir)).To(Succeed())
+		})
+
+		it("skips the checks", func() {
+			Expect(internal.Run(environment, workingDir)).To(Succeed())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when there is no venv", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(venv)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("failed to find the venv: there is no venv in %s", venvsDir)))
+			})
+		})
+
+		context("when there are several venvs and none belongs to the project", func() {
+			it.Before(func() {
+				environment["POETRY_RUN_PREFLIGHT_PROJECT"] = "another-app"
+				writeVenv(filepath.Join(venvsDir, "other-app-12345678-py3.11"), "3.11.9")
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to find the venv: there are several venvs in %s", venvsDir))))
+			})
+		})
+
+		context("when the venv interpreter is missing", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(cpythonBin, "python3.11"))).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("the venv interpreter %s is missing or points to a missing file: rebuild the image", filepath.Join(venv, "bin", "python"))))
+			})
+		})
+
+		context("when the venv was built against another Python version", func() {
+			it.Before(func() {
+				delete(environment, "POETRY_RUN_PREFLIGHT_PYTHON_VERSION")
+				Expect(os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte(fmt.Sprintf("home = %s\nversion = 3.10.14\n", cpythonBin)), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("the venv in %s was built against Python 3.10 in %s, which is no longer available: rebuild the image", venv, cpythonBin)))
+			})
+		})
+
+		context("when the venv uses another Python than at build time", func() {
+			it.Before(func() {
+				environment["POETRY_RUN_PREFLIGHT_PYTHON_VERSION"] = "3.12.1"
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("the venv in %s uses Python 3.11, but the image was built with Python 3.12: rebuild the image", venv)))
+			})
+		})
+
+		context("when python3 on PATH is another Python", func() {
+			var otherBin string
+
+			it.Before(func() {
+				otherBin = filepath.Join(layersDir, "other", "bin")
+				Expect(os.MkdirAll(otherBin, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(otherBin, "python3"), nil, 0755)).To(Succeed())
+				environment["PATH"] = otherBin + ":" + cpythonBin
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("the venv in %s was built against the Python in %s, but python3 on PATH is %s: rebuild the image", venv, cpythonBin, filepath.Join(otherBin, "python3"))))
+			})
+		})
+
+		context("when the script is not installed", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(venv, "bin", "some-script"))).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := internal.Run(environment, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("the script some-script is not installed in the venv in %s: check that the project is installed, e.g. that package-mode is not false", venv)))
+			})
+		})
+	})
+}
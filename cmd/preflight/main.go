@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paketo-buildpacks/poetry-run/cmd/preflight/internal"
+)
+
+func main() {
+	environment := map[string]string{}
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		environment[name] = value
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = internal.Run(environment, workingDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "poetry-run preflight check failed: %s\n", err)
+		os.Exit(1)
+	}
+}
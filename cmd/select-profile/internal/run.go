@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// ProfileEnv names the profile to run. It is read at launch.
+	ProfileEnv = "BPL_POETRY_RUN_PROFILE"
+
+	// TargetEnv holds the poetry run target of the launch process.
+	TargetEnv = "POETRY_RUN_TARGET"
+
+	defaultProfile = "default"
+)
+
+// Run selects the poetry run target of the profile named by
+// BPL_POETRY_RUN_PROFILE and writes it to output as POETRY_RUN_TARGET, in the
+// TOML format that exec.d expects. When no profile is named, or the default
+// profile is named, nothing is written and the default target is run.
+func Run(environment map[string]string, output io.Writer) error {
+	profile := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(environment[ProfileEnv])), "_", "-")
+	if profile == "" || profile == defaultProfile {
+		return nil
+	}
+
+	target, ok := environment[profileEnvVar(profile)]
+	if !ok {
+		return fmt.Errorf("failed to select profile %q set by %s: available profiles are %s", profile, ProfileEnv, strings.Join(profiles(environment), ", "))
+	}
+
+	err := toml.NewEncoder(output).Encode(map[string]string{TargetEnv: target})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", TargetEnv, err)
+	}
+
+	return nil
+}
+
+func profileEnvVar(profile string) string {
+	return TargetEnv + "_" + strings.ToUpper(strings.ReplaceAll(profile, "-", "_"))
+}
+
+func profiles(environment map[string]string) []string {
+	var names []string
+	for name := range environment {
+		suffix, found := strings.CutPrefix(name, TargetEnv+"_")
+		if found && suffix != "" {
+			names = append(names, strings.ReplaceAll(strings.ToLower(suffix), "_", "-"))
+		}
+	}
+	sort.Strings(names)
+
+	return append([]string{defaultProfile}, names...)
+}
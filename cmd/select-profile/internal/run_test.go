@@ -0,0 +1,85 @@
+package internal_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/paketo-buildpacks/poetry-run/cmd/select-profile/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		environment map[string]string
+		output      *bytes.Buffer
+	)
+
+	it.Before(func() {
+		environment = map[string]string{
+			"POETRY_RUN_TARGET":           "some-script",
+			"POETRY_RUN_TARGET_DEV":       "python manage.py runserver",
+			"POETRY_RUN_TARGET_LOAD_TEST": "locust",
+		}
+		output = bytes.NewBuffer(nil)
+	})
+
+	context("when BPL_POETRY_RUN_PROFILE names a profile", func() {
+		it.Before(func() {
+			environment["BPL_POETRY_RUN_PROFILE"] = "dev"
+		})
+
+		it("writes the target of the profile", func() {
+			Expect(internal.Run(environment, output)).To(Succeed())
+			Expect(output.String()).To(Equal("POETRY_RUN_TARGET = \"python manage.py runserver\"\n"))
+		})
+	})
+
+	context("when the profile name contains a dash or an underscore", func() {
+		it("writes the target of the profile", func() {
+			environment["BPL_POETRY_RUN_PROFILE"] = "load-test"
+			Expect(internal.Run(environment, output)).To(Succeed())
+			Expect(output.String()).To(Equal("POETRY_RUN_TARGET = \"locust\"\n"))
+
+			output.Reset()
+
+			environment["BPL_POETRY_RUN_PROFILE"] = "LOAD_TEST"
+			Expect(internal.Run(environment, output)).To(Succeed())
+			Expect(output.String()).To(Equal("POETRY_RUN_TARGET = \"locust\"\n"))
+		})
+	})
+
+	context("when BPL_POETRY_RUN_PROFILE is not set", func() {
+		it("falls back to the default profile", func() {
+			Expect(internal.Run(environment, output)).To(Succeed())
+			Expect(output.String()).To(BeEmpty())
+		})
+	})
+
+	context("when BPL_POETRY_RUN_PROFILE names the default profile", func() {
+		it.Before(func() {
+			environment["BPL_POETRY_RUN_PROFILE"] = "default"
+		})
+
+		it("falls back to the default profile", func() {
+			Expect(internal.Run(environment, output)).To(Succeed())
+			Expect(output.String()).To(BeEmpty())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BPL_POETRY_RUN_PROFILE names an unknown profile", func() {
+			it.Before(func() {
+				environment["BPL_POETRY_RUN_PROFILE"] = "prod"
+			})
+
+			it("returns an error listing the available profiles", func() {
+				err := internal.Run(environment, output)
+				Expect(err).To(MatchError(`failed to select profile "prod" set by BPL_POETRY_RUN_PROFILE: available profiles are default, dev, load-test`))
+			})
+		})
+	})
+}
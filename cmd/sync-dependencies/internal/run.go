@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// signalNames are the signals that can be given with '--stop-signal'.
+var signalNames = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+type watchFlag []string
+
+func (f *watchFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *watchFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Run runs the command that follows '--' in args and restarts it whenever one
+// of the files given with '--watch', e.g. poetry.lock, changes and the
+// '--install' command, 'poetry install --sync' by default, succeeds. When the
+// install fails, the running command is kept and the error is logged. The
+// files are polled every '--interval'.
+//
+// The command is stopped with '--stop-signal', SIGTERM by default, and killed
+// when it has not exited within '--stop-timeout', 10s by default. Any other
+// signal received on signals is forwarded to the command unchanged, e.g.
+// SIGHUP to make it reload its configuration.
+//
+// Run returns when the command exits, with its error, or when ctx is done or
+// the stop signal is received, after stopping the command.
+func Run(ctx context.Context, signals <-chan os.Signal, args []string, output, logs io.Writer) error {
+	flags := flag.NewFlagSet("sync-dependencies", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	var watchPaths watchFlag
+	flags.Var(&watchPaths, "watch", "file whose changes trigger an install, may be repeated")
+	install := flags.String("install", "poetry install --sync", "command that installs the dependencies")
+	interval := flags.Duration("interval", time.Second, "interval at which the files are checked for changes")
+	stopSignalName := flags.String("stop-signal", "SIGTERM", "signal that stops the command")
+	stopTimeout := flags.Duration("stop-timeout", 10*time.Second, "how long to wait for the command to stop before it is killed")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse arguments: %w", err)
+	}
+
+	command := flags.Args()
+	if len(command) == 0 {
+		return errors.New("failed to parse arguments: a command must follow --")
+	}
+
+	stopSignal, ok := signalNames[*stopSignalName]
+	if !ok {
+		return fmt.Errorf("failed to parse arguments: invalid --stop-signal %s", *stopSignalName)
+	}
+
+	installCommand := strings.Fields(*install)
+	if len(installCommand) == 0 {
+		return errors.New("failed to parse arguments: --install must not be empty")
+	}
+
+	fingerprint := fingerprintFiles(watchPaths)
+
+	process, exited, err := start(command, output, logs)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			stop(process, exited, stopSignal, *stopTimeout)
+			return nil
+
+		case received := <-signals:
+			if received == stopSignal {
+				stop(process, exited, stopSignal, *stopTimeout)
+				return nil
+			}
+
+			_ = process.Signal(received)
+
+		case err := <-exited:
+			return err
+
+		case <-ticker.C:
+			current := fingerprintFiles(watchPaths)
+			if current == fingerprint {
+				continue
+			}
+			fingerprint = current
+
+			fmt.Fprintf(logs, "Dependencies changed in %s, running %s\n", strings.Join(watchPaths, ", "), strings.Join(installCommand, " "))
+
+			cmd := exec.CommandContext(ctx, installCommand[0], installCommand[1:]...)
+			cmd.Stdout = logs
+			cmd.Stderr = logs
+			err := cmd.Run()
+			if err != nil {
+				fmt.Fprintf(logs, "failed to sync dependencies: %s: %s, keeping the running process\n", strings.Join(installCommand, " "), err)
+				continue
+			}
+
+			fmt.Fprintln(logs, "Synced dependencies, restarting the process")
+			stop(process, exited, stopSignal, *stopTimeout)
+
+			process, exited, err = start(command, output, logs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// start starts the command and returns a channel that receives the result of
+// waiting for it.
+func start(command []string, output, logs io.Writer) (*os.Process, chan error, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = output
+	cmd.Stderr = logs
+
+	err := cmd.Start()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	return cmd.Process, exited, nil
+}
+
+// stop sends the stop signal to the process and kills it when it has not
+// exited within the timeout.
+func stop(process *os.Process, exited chan error, signal syscall.Signal, timeout time.Duration) {
+	_ = process.Signal(signal)
+
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		_ = process.Kill()
+		<-exited
+	}
+}
+
+// fingerprintFiles returns a digest of the contents of the given files.
+// Missing files are treated as empty.
+func fingerprintFiles(paths []string) string {
+	hash := sha256.New()
+	for _, path := range paths {
+		content, _ := os.ReadFile(path)
+		fmt.Fprintf(hash, "%s\x00%d\x00", path, len(content))
+		hash.Write(content)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
@@ -0,0 +1,158 @@
+package internal_test
+
+import (
+	gocontext "context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+	"github.com/paketo-buildpacks/poetry-run/cmd/sync-dependencies/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect       = NewWithT(t).Expect
+		Eventually   = NewWithT(t).Eventually
+		Consistently = NewWithT(t).Consistently
+
+		dir    string
+		lock   string
+		output *gbytes.Buffer
+		logs   *gbytes.Buffer
+
+		ctx     gocontext.Context
+		cancel  gocontext.CancelFunc
+		signals chan os.Signal
+		done    chan error
+	)
+
+	it.Before(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "app")
+		Expect(err).NotTo(HaveOccurred())
+
+		lock = filepath.Join(dir, "poetry.lock")
+		Expect(os.WriteFile(lock, []byte("lock-version = 1"), 0600)).To(Succeed())
+
+		output = gbytes.NewBuffer()
+		logs = gbytes.NewBuffer()
+
+		ctx, cancel = gocontext.WithCancel(gocontext.Background())
+		signals = make(chan os.Signal, 1)
+		done = make(chan error, 1)
+	})
+
+	it.After(func() {
+		cancel()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	run := func(install string, flags ...string) {
+		args := append([]string{
+			"--watch", lock,
+			"--install", install,
+			"--interval", "10ms",
+		}, flags...)
+		args = append(args, "--", "sh", "-c", `trap 'echo hangup' HUP; trap 'echo interrupted; exit 0' INT; echo started; while true; do sleep 0.01; done`)
+
+		go func() {
+			done <- internal.Run(ctx, signals, args, output, logs)
+		}()
+
+		Eventually(output).Should(gbytes.Say("started"))
+	}
+
+	it("restarts the command after installing the changed dependencies", func() {
+		run("true")
+
+		Expect(os.WriteFile(lock, []byte("lock-version = 2"), 0600)).To(Succeed())
+
+		Eventually(logs).Should(gbytes.Say("Dependencies changed in %s, running true", lock))
+		Eventually(logs).Should(gbytes.Say("Synced dependencies, restarting the process"))
+		Eventually(output).Should(gbytes.Say("started"))
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	it("keeps the running command when the install fails", func() {
+		run("false")
+
+		Expect(os.WriteFile(lock, []byte("lock-version = 2"), 0600)).To(Succeed())
+
+		Eventually(logs).Should(gbytes.Say("failed to sync dependencies: false: exit status 1, keeping the running process"))
+		Consistently(output, 100*time.Millisecond).ShouldNot(gbytes.Say("started"))
+		Expect(done).NotTo(Receive())
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	it("does not restart the command when the files are unchanged", func() {
+		run("true")
+
+		Expect(os.WriteFile(lock, []byte("lock-version = 1"), 0600)).To(Succeed())
+
+		Consistently(logs, 100*time.Millisecond).ShouldNot(gbytes.Say("Dependencies changed"))
+	})
+
+	it("forwards signals other than the stop signal to the command and keeps running", func() {
+		run("true")
+
+		signals <- syscall.SIGHUP
+
+		Eventually(output).Should(gbytes.Say("hangup"))
+		Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+	})
+
+	it("stops the command and returns when the stop signal is received", func() {
+		run("true")
+
+		signals <- syscall.SIGTERM
+
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	context("with --stop-signal", func() {
+		it("stops the command with the given signal", func() {
+			run("true", "--stop-signal", "SIGINT", "--stop-timeout", "5s")
+
+			signals <- syscall.SIGINT
+
+			Eventually(output).Should(gbytes.Say("interrupted"))
+			Eventually(done).Should(Receive(BeNil()))
+		})
+	})
+
+	it("returns the error of the command when it exits", func() {
+		err := internal.Run(ctx, signals, []string{"--watch", lock, "--", "sh", "-c", "exit 3"}, output, logs)
+
+		var exitErr *exec.ExitError
+		Expect(err).To(BeAssignableToTypeOf(exitErr))
+		Expect(err.(*exec.ExitError).ExitCode()).To(Equal(3))
+	})
+
+	context("failure cases", func() {
+		it("returns an error when no command is given", func() {
+			err := internal.Run(ctx, signals, []string{"--watch", lock}, output, logs)
+			Expect(err).To(MatchError("failed to parse arguments: a command must follow --"))
+		})
+
+		it("returns an error when the stop signal is invalid", func() {
+			err := internal.Run(ctx, signals, []string{"--stop-signal", "SIGFOO", "--", "true"}, output, logs)
+			Expect(err).To(MatchError("failed to parse arguments: invalid --stop-signal SIGFOO"))
+		})
+
+		it("returns an error when the command cannot be started", func() {
+			err := internal.Run(ctx, signals, []string{"--", filepath.Join(dir, "missing")}, output, logs)
+			Expect(err).To(MatchError(ContainSubstring("failed to start %s", filepath.Join(dir, "missing"))))
+		})
+	})
+}
@@ -22,3 +22,35 @@ const VenvLayerName = "poetry-venv"
 
 // CacheLayerName holds the poetry cache.
 const CacheLayerName = "cache"
+
+// ProfilesLayerName is the name of the layer that holds the run profiles and
+// the exec.d helper that selects one of them at launch.
+const ProfilesLayerName = "profiles"
+
+// DotenvLayerName is the name of the layer that holds the exec.d helper that
+// loads .env files at launch.
+const DotenvLayerName = "dotenv"
+
+// HealthLayerName is the name of the layer that holds the built-in health
+// check binary.
+const HealthLayerName = "health"
+
+// SyncLayerName is the name of the layer that holds the supervisor that syncs
+// the dependencies of a reloadable process.
+const SyncLayerName = "sync-dependencies"
+
+// PoetryConfigLayerName is the name of the layer that overrides conflicting
+// poetry.toml settings at launch.
+const PoetryConfigLayerName = "poetry-config"
+
+// PreflightLayerName is the name of the layer that holds the exec.d helper
+// that checks the venv and the target at launch.
+const PreflightLayerName = "preflight"
+
+// ProcessEnvLayerName is the name of the layer that holds the environment
+// variables of individual processes.
+const ProcessEnvLayerName = "process-env"
+
+// ReportLayerName is the name of the layer that holds the build report. It
+// is a launch layer, so the report is exported to the image.
+const ReportLayerName = "poetry-run"
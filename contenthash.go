@@ -0,0 +1,188 @@
+package poetryrun
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// legacyContentHashKeys are the [tool.poetry] keys that Poetry always hashes,
+// as null when they are missing, unless [project] has relevant content.
+var legacyContentHashKeys = []string{"dependencies", "source", "extras", "dev-dependencies"}
+
+// contentHashKeys are the [tool.poetry] keys that Poetry hashes.
+var contentHashKeys = append(append([]string{}, legacyContentHashKeys...), "group")
+
+// projectContentHashKeys are the [project] keys that Poetry hashes.
+var projectContentHashKeys = []string{"requires-python", "dependencies", "optional-dependencies"}
+
+// contentHash computes the hash of the dependency relevant content of a
+// pyproject.toml, as Poetry records it in poetry.lock under
+// [metadata] content-hash: the SHA-256 of the relevant keys, serialized with
+// Python's json.dumps(content, sort_keys=True).
+func contentHash(raw map[string]interface{}) string {
+	project, _ := raw["project"].(map[string]interface{})
+
+	var tool map[string]interface{}
+	if tools, ok := raw["tool"].(map[string]interface{}); ok {
+		tool, _ = tools["poetry"].(map[string]interface{})
+	}
+
+	relevantProject := map[string]interface{}{}
+	for _, key := range projectContentHashKeys {
+		if value, ok := project[key]; ok {
+			relevantProject[key] = value
+		}
+	}
+
+	relevantPoetry := map[string]interface{}{}
+	for _, key := range contentHashKeys {
+		value, ok := tool[key]
+		if !ok && (indexOf(legacyContentHashKeys, key) < 0 || len(relevantProject) > 0) {
+			continue
+		}
+		relevantPoetry[key] = value
+	}
+
+	relevant := relevantPoetry
+	if len(relevantProject) > 0 {
+		relevant = map[string]interface{}{
+			"project": relevantProject,
+			"tool":    map[string]interface{}{"poetry": relevantPoetry},
+		}
+	}
+
+	if groups, ok := raw["dependency-groups"].(map[string]interface{}); ok && len(groups) > 0 {
+		relevant["dependency-groups"] = groups
+	}
+
+	var content strings.Builder
+	writePythonJSON(&content, relevant)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content.String())))
+}
+
+// writePythonJSON writes the value as Python's json.dumps does with
+// sort_keys=True and the default separators and ASCII escaping.
+func writePythonJSON(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
+
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+
+	case float64:
+		b.WriteString(pythonFloat(v))
+
+	case string:
+		writePythonString(b, v)
+
+	case time.Time:
+		writePythonString(b, v.Format(time.RFC3339Nano))
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("{")
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writePythonString(b, key)
+			b.WriteString(": ")
+			writePythonJSON(b, v[key])
+		}
+		b.WriteString("}")
+
+	case []map[string]interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, table := range v {
+			values = append(values, table)
+		}
+		writePythonJSON(b, values)
+
+	case []interface{}:
+		b.WriteString("[")
+		for i, element := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writePythonJSON(b, element)
+		}
+		b.WriteString("]")
+
+	default:
+		writePythonString(b, fmt.Sprint(v))
+	}
+}
+
+func writePythonString(b *strings.Builder, s string) {
+	b.WriteString(`"`)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			high, low := utf16.EncodeRune(r)
+			fmt.Fprintf(b, `\u%04x\u%04x`, high, low)
+		default:
+			fmt.Fprintf(b, `\u%04x`, r)
+		}
+	}
+	b.WriteString(`"`)
+}
+
+// pythonFloat formats a float as Python's repr does.
+func pythonFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	exponent := 0
+	if f != 0 {
+		exponent = int(math.Floor(math.Log10(math.Abs(f))))
+	}
+
+	if exponent < -4 || exponent >= 16 {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+
+	return s
+}
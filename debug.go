@@ -0,0 +1,116 @@
+package poetryrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// debugPort is the port debugpy listens on when BPL_DEBUG_PORT is not set at
+// launch. It matches the debugpy default.
+const debugPort = "5678"
+
+// debugProcess returns a 'debug' process that runs the given poetry run
+// target under debugpy. Script keys are resolved through the given
+// pyproject.toml scripts so that the underlying module or callable can be
+// handed to debugpy, since debugpy cannot wrap a console script directly.
+//
+// The process runs through bash so that BPL_DEBUG_PORT is expanded at launch.
+// The default args of the target are passed on to bash as positional
+// parameters, so that they can be overridden at launch like those of the
+// other processes.
+func debugProcess(target Target, scripts map[string]Script, waitForClient bool) (packit.DirectProcess, error) {
+	debugTarget, err := debugpyTarget(target, scripts)
+	if err != nil {
+		return packit.DirectProcess{}, err
+	}
+
+	script := []string{
+		"exec", "poetry", "run", "python", "-m", "debugpy",
+		"--listen", fmt.Sprintf("0.0.0.0:${BPL_DEBUG_PORT:-%s}", debugPort),
+	}
+
+	if waitForClient {
+		script = append(script, "--wait-for-client")
+	}
+
+	for _, arg := range debugTarget {
+		script = append(script, shellQuote(arg))
+	}
+
+	script = append(script, `"$@"`)
+
+	_, args := target.Split()
+
+	return packit.DirectProcess{
+		Type:    "debug",
+		Command: []string{"bash", "-c", strings.Join(script, " "), "debug"},
+		Args:    args,
+	}, nil
+}
+
+// executableModules maps the executables of common servers and task queues
+// to the modules that run them with 'python -m', so that debugpy can run them.
+var executableModules = map[string]string{
+	"celery":       "celery",
+	"daphne":       "daphne",
+	"django-admin": "django",
+	"dramatiq":     "dramatiq",
+	"flask":        "flask",
+	"gunicorn":     "gunicorn",
+	"hypercorn":    "hypercorn",
+	"uvicorn":      "uvicorn",
+}
+
+// debugpyTarget translates the fixed command of a poetry run target into the
+// arguments that debugpy expects after its own flags: '-m <module>',
+// '-c <code>' or a script file. The default args of the target follow these
+// at launch.
+func debugpyTarget(target Target, scripts map[string]Script) ([]string, error) {
+	command, args := target.Split()
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no run target found")
+	}
+
+	if isPythonExecutable(command[0]) {
+		if len(command) == 3 && (command[1] == "-m" || command[1] == "-c") {
+			return command[1:], nil
+		}
+
+		// 'python <file>': the script file is the first of the default args.
+		if len(args) >= 1 && !strings.HasPrefix(args[0], "-") {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%q does not name a module or a script file", strings.Join(target.Argv, " "))
+	}
+
+	if script, ok := scripts[command[0]]; ok {
+		return script.PythonArgs(), nil
+	}
+
+	if module, ok := executableModules[filepath.Base(command[0])]; ok {
+		return []string{"-m", module}, nil
+	}
+
+	return nil, fmt.Errorf("%q is neither a python invocation, a script defined in pyproject.toml nor an executable that debugpy can run as a module", command[0])
+}
+
+var pythonExecutable = regexp.MustCompile(`^python(\d+(\.\d+)?)?$`)
+
+func isPythonExecutable(name string) bool {
+	return pythonExecutable.MatchString(name)
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
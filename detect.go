@@ -1,15 +1,23 @@
 package poetryrun
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/paketo-buildpacks/libreload-packit"
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
 //go:generate faux --interface PyProjectParser --output fakes/py_project_parser.go
 
+//go:generate faux --interface LockFileParser --output fakes/lock_file_parser.go
+
+//go:generate faux --interface ConfigFileParser --output fakes/config_file_parser.go
+
 type Reloader libreload.Reloader
 
 //go:generate faux --interface Reloader --output fakes/reloader.go
@@ -18,30 +26,65 @@ type Reloader libreload.Reloader
 // requirements.
 type BuildPlanMetadata struct {
 	// Build denotes the dependency is needed at build-time.
+	Build bool `toml:"build,omitempty"`
+
+	// Launch denotes the dependency is needed at launch-time.
 	Launch bool `toml:"launch"`
 }
 
 type PyProjectParser interface {
-	Parse(string) (string, error)
+	Parse(string) (PyProject, error)
+}
+
+type LockFileParser interface {
+	Parse(string) (PoetryLock, error)
+}
+
+type ConfigFileParser interface {
+	Parse(string) (PoetryConfig, error)
 }
 
 // Detect will return a packit.DetectFunc that will be invoked during the
-// detect phase of the buildpack lifecycle.
+// detect phase of the buildpack lifecycle. It reads its configuration from the
+// "NAME=value" variables listed by environ, e.g. os.Environ.
+//
+// Detection will contribute a Build Plan that requires cpython, poetry and
+// poetry-venv at launch. poetry-venv is also required at build, so that the
+// venv can be found to record its Python version.
 //
-// Detection will contribute a Build Plan that provides site-packages,
-// and requires cpython and pip at build.
+// Detection is contingent on the Resolver finding a poetry run target, e.g.
+// the only script defined in the pyproject.toml under [tool.poetry.scripts].
+// When it does not, Detect logs why and how to fix it, and fails detection.
+// With `BP_POETRY_RUN_STRICT_DETECTION=true` it returns an error instead.
 //
-// Detection is contingent on there being one or more scripts to run
-// defined in the pyproject.toml under [tool.poetry.scripts]
-func Detect(pyProjectParser PyProjectParser, reloader Reloader) packit.DetectFunc {
+// When `BP_DEBUG_ENABLED` is true, debugpy must be one of the packages
+// locked in poetry.lock so that it is available in the venv at launch.
+//
+// When live reload is enabled, watchexec is required at launch unless
+// `BP_LIVE_RELOAD_STRATEGY` selects another strategy.
+func Detect(pyProjectParser PyProjectParser, lockFileParser LockFileParser, logger scribe.Emitter, reloader Reloader, environ func() []string) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
+		resolver := NewResolver(environ, os.DirFS(context.WorkingDir))
 
-		if shouldDetect, err := shouldDetect(context.WorkingDir, pyProjectParser); err != nil {
+		if shouldDetect, err := shouldDetect(resolver, context.WorkingDir, pyProjectParser, logger); err != nil {
 			return packit.DetectResult{}, err
 		} else if !shouldDetect {
 			return packit.DetectResult{}, nil
 		}
 
+		if debugEnabled, err := resolver.lookupBoolEnv("BP_DEBUG_ENABLED"); err != nil {
+			return packit.DetectResult{}, err
+		} else if debugEnabled {
+			poetryLock, err := lockFileParser.Parse(filepath.Join(context.WorkingDir, "poetry.lock"))
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if !poetryLock.HasPackage("debugpy") {
+				return packit.DetectResult{}, fmt.Errorf("BP_DEBUG_ENABLED is set but debugpy is not a dependency in poetry.lock: add it to the project with 'poetry add --group dev debugpy'")
+			}
+		}
+
 		requirements := []packit.BuildPlanRequirement{
 			{
 				Name: CPython,
@@ -58,14 +101,15 @@ func Detect(pyProjectParser PyProjectParser, reloader Reloader) packit.DetectFun
 			{
 				Name: PoetryVenv,
 				Metadata: BuildPlanMetadata{
+					Build:  true,
 					Launch: true,
 				},
 			},
 		}
 
-		if shouldReload, err := reloader.ShouldEnableLiveReload(); err != nil {
+		if strategy, shouldReload, err := resolver.liveReload(reloader); err != nil {
 			return packit.DetectResult{}, err
-		} else if shouldReload {
+		} else if shouldReload && strategy == WatchexecStrategy {
 			requirements = append(requirements, packit.BuildPlanRequirement{
 				Name: Watchexec,
 				Metadata: BuildPlanMetadata{
@@ -82,16 +126,70 @@ func Detect(pyProjectParser PyProjectParser, reloader Reloader) packit.DetectFun
 	}
 }
 
-func shouldDetect(workingDir string, pyProjectParser PyProjectParser) (shouldDetect bool, err error) {
-	if _, hasRunTarget := os.LookupEnv("BP_POETRY_RUN_TARGET"); hasRunTarget {
-		return true, nil
+func shouldDetect(resolver Resolver, workingDir string, pyProjectParser PyProjectParser, logger scribe.Emitter) (shouldDetect bool, err error) {
+	pyProject, err := parsePyProject(resolver, workingDir, pyProjectParser)
+	if err == nil {
+		_, err = resolver.Resolve(pyProject)
 	}
 
-	if script, err := pyProjectParser.Parse(filepath.Join(workingDir, "pyproject.toml")); err != nil {
-		return false, err
-	} else if script == "" {
-		return false, packit.Fail.WithMessage("Expects one and exactly one script defined in pyproject.toml")
+	if err != nil {
+		var detectionErr DetectionError
+		if !errors.As(err, &detectionErr) {
+			return false, err
+		}
+
+		message := explainDetectionFailure(detectionErr)
+		logger.Process("No poetry run target found")
+		logger.Subprocess(message)
+		logger.Break()
+
+		if strict, err := resolver.lookupBoolEnv("BP_POETRY_RUN_STRICT_DETECTION"); err != nil {
+			return false, err
+		} else if strict {
+			return false, fmt.Errorf("failed to find a poetry run target: %w", detectionErr)
+		}
+
+		return false, packit.Fail.WithMessage("%s", message)
 	}
 
 	return true, nil
 }
+
+// parsePyProject parses the pyproject.toml in workingDir. An explicit
+// BP_POETRY_RUN_TARGET does not need pyproject.toml, so when it is set a
+// syntax error in the file is ignored and an empty PyProject is returned, both
+// at detect and at build.
+func parsePyProject(resolver Resolver, workingDir string, pyProjectParser PyProjectParser) (PyProject, error) {
+	pyProject, err := pyProjectParser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+
+	var detectionErr DetectionError
+	if errors.As(err, &detectionErr) && detectionErr.Reason == InvalidTOML {
+		if _, ok := resolver.lookupEnv("BP_POETRY_RUN_TARGET"); ok {
+			return PyProject{}, nil
+		}
+	}
+
+	return pyProject, err
+}
+
+// explainDetectionFailure returns an actionable message describing how to
+// fix the given detection failure.
+func explainDetectionFailure(err DetectionError) string {
+	switch err.Reason {
+	case MissingPyProject:
+		return "No pyproject.toml found in the app directory. Add a pyproject.toml with exactly one script under [tool.poetry.scripts], or set BP_POETRY_RUN_TARGET."
+	case NoScripts:
+		return "pyproject.toml defines no scripts under [tool.poetry.scripts]. Define exactly one script, or set BP_POETRY_RUN_TARGET."
+	case MultipleScripts:
+		return fmt.Sprintf("pyproject.toml defines %d scripts under [tool.poetry.scripts] (%s). Keep exactly one script, or set BP_POETRY_RUN_TARGET to the one to run.", len(err.Scripts), strings.Join(err.Scripts, ", "))
+	case AmbiguousScripts:
+		if len(err.Matches) > 0 {
+			return fmt.Sprintf("pyproject.toml defines scripts %s under [tool.poetry.scripts], which match the same preferred script name. Rename all but one of them, or set BP_POETRY_RUN_TARGET to the one to run.", strings.Join(err.Matches, ", "))
+		}
+		return fmt.Sprintf("pyproject.toml defines %d scripts under [tool.poetry.scripts] (%s), but none is named %s. Add one of these names to BP_POETRY_RUN_PREFERRED_SCRIPTS, or set BP_POETRY_RUN_TARGET to the one to run.", len(err.Scripts), strings.Join(err.Scripts, ", "), strings.Join(err.Preferred, ", "))
+	case InvalidTOML:
+		return fmt.Sprintf("pyproject.toml is not valid TOML at line %d, column %d (%s). Fix the syntax error, or set BP_POETRY_RUN_TARGET.", err.Line, err.Column, tomlErrorMessage(err.Err))
+	}
+
+	return err.Error()
+}
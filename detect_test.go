@@ -1,6 +1,7 @@
 package poetryrun_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 	poetryrun "github.com/paketo-buildpacks/poetry-run"
 	"github.com/paketo-buildpacks/poetry-run/fakes"
 	"github.com/sclevine/spec"
@@ -19,16 +21,20 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect = NewWithT(t).Expect
 		detect packit.DetectFunc
+		buffer *bytes.Buffer
 
 		pyProjectParser *fakes.PyProjectParser
+		lockFileParser  *fakes.LockFileParser
 		reloader        *fakes.Reloader
 	)
 
 	it.Before(func() {
 		pyProjectParser = &fakes.PyProjectParser{}
+		lockFileParser = &fakes.LockFileParser{}
 		reloader = &fakes.Reloader{}
+		buffer = bytes.NewBuffer(nil)
 
-		detect = poetryrun.Detect(pyProjectParser, reloader)
+		detect = poetryrun.Detect(pyProjectParser, lockFileParser, scribe.NewEmitter(buffer), reloader, os.Environ)
 	})
 
 	context("with BP_POETRY_RUN_TARGET not set", func() {
@@ -38,7 +44,11 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 
 		context("when pyproject.toml parser returns a valid script", func() {
 			it.Before(func() {
-				pyProjectParser.ParseCall.Returns.String = "some-script"
+				pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+					Path:    "some-path",
+					Exists:  true,
+					Scripts: map[string]poetryrun.Script{"some-script": {Name: "some-script"}},
+				}
 			})
 
 			it("returns a build plan", func() {
@@ -64,6 +74,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						{
 							Name: poetryrun.PoetryVenv,
 							Metadata: poetryrun.BuildPlanMetadata{
+								Build:  true,
 								Launch: true,
 							},
 						},
@@ -99,6 +110,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 							{
 								Name: poetryrun.PoetryVenv,
 								Metadata: poetryrun.BuildPlanMetadata{
+									Build:  true,
 									Launch: true,
 								},
 							},
@@ -111,18 +123,178 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						},
 					}))
 				})
+
+				context("with BP_LIVE_RELOAD_STRATEGY=framework-native", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "framework-native")).To(Succeed())
+					})
+
+					it.After(func() {
+						Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+					})
+
+					it("does not require watchexec", func() {
+						result, err := detect(packit.DetectContext{})
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(result.Plan.Requires).To(HaveLen(3))
+						Expect(result.Plan.Requires).NotTo(ContainElement(HaveField("Name", poetryrun.Watchexec)))
+					})
+				})
+
+				context("with BP_LIVE_RELOAD_STRATEGY=none", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "none")).To(Succeed())
+					})
+
+					it.After(func() {
+						Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+					})
+
+					it("does not require watchexec", func() {
+						result, err := detect(packit.DetectContext{})
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(result.Plan.Requires).To(HaveLen(3))
+						Expect(reloader.ShouldEnableLiveReloadCall.CallCount).To(Equal(0))
+					})
+				})
 			})
 		})
 
 		context("when the pyproject.toml parser cannot find a script", func() {
-			it.Before(func() {
-				pyProjectParser.ParseCall.Returns.String = ""
+			context("because there is no pyproject.toml", func() {
+				it.Before(func() {
+					pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+						Path: "some-path",
+					}
+				})
+
+				it("explains why and fails detection", func() {
+					_, err := detect(packit.DetectContext{})
+
+					message := "No pyproject.toml found in the app directory. Add a pyproject.toml with exactly one script under [tool.poetry.scripts], or set BP_POETRY_RUN_TARGET."
+					Expect(err).To(MatchError(packit.Fail.WithMessage("%s", message)))
+					Expect(buffer.String()).To(ContainSubstring("No poetry run target found"))
+					Expect(buffer.String()).To(ContainSubstring(message))
+				})
 			})
 
-			it("fails detection", func() {
-				_, err := detect(packit.DetectContext{})
+			context("because there are no scripts", func() {
+				it.Before(func() {
+					pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+						Path:   "some-path",
+						Exists: true,
+					}
+				})
+
+				it("explains why and fails detection", func() {
+					_, err := detect(packit.DetectContext{})
+
+					message := "pyproject.toml defines no scripts under [tool.poetry.scripts]. Define exactly one script, or set BP_POETRY_RUN_TARGET."
+					Expect(err).To(MatchError(packit.Fail.WithMessage("%s", message)))
+					Expect(buffer.String()).To(ContainSubstring(message))
+				})
+			})
+
+			context("because there are multiple scripts", func() {
+				it.Before(func() {
+					pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+						Path:   "some-path",
+						Exists: true,
+						Scripts: map[string]poetryrun.Script{
+							"some-script":       {Name: "some-script"},
+							"some-other-script": {Name: "some-other-script"},
+						},
+					}
+				})
+
+				it("lists the scripts and fails detection", func() {
+					_, err := detect(packit.DetectContext{})
+
+					message := "pyproject.toml defines 2 scripts under [tool.poetry.scripts] (some-other-script, some-script). Keep exactly one script, or set BP_POETRY_RUN_TARGET to the one to run."
+					Expect(err).To(MatchError(packit.Fail.WithMessage("%s", message)))
+					Expect(buffer.String()).To(ContainSubstring(message))
+				})
+			})
+
+			context("because the preferred script names do not pick one of the scripts", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_POETRY_RUN_PREFER_SCRIPTS", "true")).To(Succeed())
+					pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+						Path:   "some-path",
+						Exists: true,
+						Scripts: map[string]poetryrun.Script{
+							"some-script":       {Name: "some-script"},
+							"some-other-script": {Name: "some-other-script"},
+						},
+					}
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_POETRY_RUN_PREFER_SCRIPTS")).To(Succeed())
+				})
+
+				it("lists the preferred names and fails detection", func() {
+					_, err := detect(packit.DetectContext{})
+
+					message := "pyproject.toml defines 2 scripts under [tool.poetry.scripts] (some-other-script, some-script), but none is named web, serve, server, start, app. Add one of these names to BP_POETRY_RUN_PREFERRED_SCRIPTS, or set BP_POETRY_RUN_TARGET to the one to run."
+					Expect(err).To(MatchError(packit.Fail.WithMessage("%s", message)))
+					Expect(buffer.String()).To(ContainSubstring(message))
+				})
+
+				it("passes detection when one of them has a preferred name", func() {
+					pyProjectParser.ParseCall.Returns.PyProject.Scripts["web"] = poetryrun.Script{Name: "web"}
+
+					result, err := detect(packit.DetectContext{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Plan.Requires).To(HaveLen(3))
+				})
+			})
+
+			context("because pyproject.toml is not valid TOML", func() {
+				it.Before(func() {
+					pyProjectParser.ParseCall.Returns.Error = poetryrun.DetectionError{
+						Reason: poetryrun.InvalidTOML,
+						Path:   "some-path",
+						Line:   3,
+						Column: 7,
+						Err:    errors.New("expected a value"),
+					}
+				})
+
+				it("reports the position of the syntax error and fails detection", func() {
+					_, err := detect(packit.DetectContext{})
+
+					message := "pyproject.toml is not valid TOML at line 3, column 7 (expected a value). Fix the syntax error, or set BP_POETRY_RUN_TARGET."
+					Expect(err).To(MatchError(packit.Fail.WithMessage("%s", message)))
+					Expect(buffer.String()).To(ContainSubstring(message))
+				})
+			})
+
+			context("when BP_POETRY_RUN_STRICT_DETECTION is true", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_POETRY_RUN_STRICT_DETECTION", "true")).To(Succeed())
+					pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+						Path:   "some-path",
+						Exists: true,
+					}
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_POETRY_RUN_STRICT_DETECTION")).To(Succeed())
+				})
 
-				Expect(err).To(MatchError(packit.Fail.WithMessage("Expects one and exactly one script defined in pyproject.toml")))
+				it("returns an error instead of failing detection", func() {
+					_, err := detect(packit.DetectContext{})
+
+					Expect(err).To(MatchError("failed to find a poetry run target: no scripts defined under [tool.poetry.scripts] in some-path"))
+					Expect(errors.Is(err, packit.Fail)).To(BeFalse())
+
+					var detectionErr poetryrun.DetectionError
+					Expect(errors.As(err, &detectionErr)).To(BeTrue())
+					Expect(detectionErr.Reason).To(Equal(poetryrun.NoScripts))
+				})
 			})
 		})
 	})
@@ -157,13 +329,33 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 					{
 						Name: poetryrun.PoetryVenv,
 						Metadata: poetryrun.BuildPlanMetadata{
+							Build:  true,
 							Launch: true,
 						},
 					},
 				},
 			}))
 
-			Expect(pyProjectParser.ParseCall.CallCount).To(Equal(0))
+			Expect(pyProjectParser.ParseCall.CallCount).To(Equal(1))
+		})
+
+		context("when pyproject.toml is not valid TOML", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.Error = poetryrun.DetectionError{
+					Reason: poetryrun.InvalidTOML,
+					Path:   "some-path",
+					Line:   3,
+					Column: 7,
+					Err:    errors.New("expected a value"),
+				}
+			})
+
+			it("still passes detection", func() {
+				result, err := detect(packit.DetectContext{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(HaveLen(3))
+				Expect(buffer.String()).NotTo(ContainSubstring("No poetry run target found"))
+			})
 		})
 
 		context("when live reload is enabled", func() {
@@ -192,6 +384,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 						{
 							Name: poetryrun.PoetryVenv,
 							Metadata: poetryrun.BuildPlanMetadata{
+								Build:  true,
 								Launch: true,
 							},
 						},
@@ -207,6 +400,57 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when BP_DEBUG_ENABLED is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_DEBUG_ENABLED", "true")).To(Succeed())
+			pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+				Path:    "some-path",
+				Exists:  true,
+				Scripts: map[string]poetryrun.Script{"some-script": {Name: "some-script"}},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_DEBUG_ENABLED")).To(Succeed())
+		})
+
+		context("when debugpy is locked in poetry.lock", func() {
+			it.Before(func() {
+				lockFileParser.ParseCall.Returns.PoetryLock = poetryrun.PoetryLock{
+					Packages: []poetryrun.PoetryLockPackage{
+						{Name: "flask", Version: "3.0.0"},
+						{Name: "debugpy", Version: "1.8.0"},
+					},
+				}
+			})
+
+			it("returns a build plan", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: "a-working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(HaveLen(3))
+
+				Expect(lockFileParser.ParseCall.Receives.String).To(Equal(filepath.Join("a-working-dir", "poetry.lock")))
+			})
+		})
+
+		context("when debugpy is not locked in poetry.lock", func() {
+			it.Before(func() {
+				lockFileParser.ParseCall.Returns.PoetryLock = poetryrun.PoetryLock{
+					Packages: []poetryrun.PoetryLockPackage{
+						{Name: "flask", Version: "3.0.0"},
+					},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{})
+				Expect(err).To(MatchError(ContainSubstring("BP_DEBUG_ENABLED is set but debugpy is not a dependency in poetry.lock")))
+			})
+		})
+	})
+
 	context("failure cases", func() {
 		context("when BP_POETRY_RUN_TARGET is not set", func() {
 			it.Before(func() {
@@ -225,6 +469,59 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when BP_DEBUG_ENABLED is set", func() {
+			it.Before(func() {
+				pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+					Path:    "some-path",
+					Exists:  true,
+					Scripts: map[string]poetryrun.Script{"some-script": {Name: "some-script"}},
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_DEBUG_ENABLED")).To(Succeed())
+			})
+
+			context("when it cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_DEBUG_ENABLED", "not-a-bool")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := detect(packit.DetectContext{})
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_DEBUG_ENABLED value not-a-bool")))
+				})
+			})
+
+			context("when the poetry.lock parser returns an error", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_DEBUG_ENABLED", "true")).To(Succeed())
+					lockFileParser.ParseCall.Returns.Error = errors.New("some lock error")
+				})
+
+				it("returns the error", func() {
+					_, err := detect(packit.DetectContext{})
+					Expect(err).To(MatchError("some lock error"))
+				})
+			})
+		})
+
+		context("when BP_POETRY_RUN_STRICT_DETECTION cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_POETRY_RUN_STRICT_DETECTION", "not-a-bool")).To(Succeed())
+				pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{Exists: true}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_POETRY_RUN_STRICT_DETECTION")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_POETRY_RUN_STRICT_DETECTION value not-a-bool")))
+			})
+		})
+
 		context("when the reloader returns an error", func() {
 			it.Before(func() {
 				reloader.ShouldEnableLiveReloadCall.Returns.Error = errors.New("failed to parse")
@@ -232,7 +529,11 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 
 			context("when pyproject.toml parser returns a valid script", func() {
 				it.Before(func() {
-					pyProjectParser.ParseCall.Returns.String = "some-script"
+					pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+						Path:    "some-path",
+						Exists:  true,
+						Scripts: map[string]poetryrun.Script{"some-script": {Name: "some-script"}},
+					}
 				})
 
 				it("returns an error", func() {
@@ -241,5 +542,25 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 				})
 			})
 		})
+
+		context("when BP_LIVE_RELOAD_STRATEGY is invalid", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_LIVE_RELOAD_STRATEGY", "inotify")).To(Succeed())
+				pyProjectParser.ParseCall.Returns.PyProject = poetryrun.PyProject{
+					Path:    "some-path",
+					Exists:  true,
+					Scripts: map[string]poetryrun.Script{"some-script": {Name: "some-script"}},
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_LIVE_RELOAD_STRATEGY")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{})
+				Expect(err).To(MatchError("invalid BP_LIVE_RELOAD_STRATEGY value inotify: must be one of watchexec, framework-native or none"))
+			})
+		})
 	})
 }
@@ -0,0 +1,34 @@
+package poetryrun
+
+import (
+	"path/filepath"
+)
+
+// defaultDotenvFiles is the .env file loaded at launch when
+// BP_POETRY_RUN_DOTENV_FILES is not set.
+const defaultDotenvFiles = ".env"
+
+// dotenvFiles returns the .env files configured with
+// BP_POETRY_RUN_DOTENV_FILES, a ':' separated list of paths relative to the
+// app directory, resolved against the given working directory.
+func (r Resolver) dotenvFiles(workingDir string) []string {
+	configured, ok := r.lookupEnv("BP_POETRY_RUN_DOTENV_FILES")
+	if !ok {
+		configured = defaultDotenvFiles
+	}
+
+	var files []string
+	for _, file := range filepath.SplitList(configured) {
+		if file == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workingDir, file)
+		}
+
+		files = append(files, file)
+	}
+
+	return files
+}
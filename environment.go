@@ -0,0 +1,23 @@
+package poetryrun
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// lookupBoolEnv reports whether the given environment variable is set to a
+// truthy value. Unset variables are false; values that cannot be parsed as a
+// boolean are an error.
+func (r Resolver) lookupBoolEnv(name string) (bool, error) {
+	value, ok := r.lookupEnv(name)
+	if !ok {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s value %s: %w", name, value, err)
+	}
+
+	return enabled, nil
+}
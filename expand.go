@@ -0,0 +1,92 @@
+package poetryrun
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expandEnv replaces '${VAR}' in the given value with the value of VAR, and
+// '${VAR:-default}' with the value of VAR or, when VAR is unset or empty,
+// with default. '$$' is a literal '$'; a '$' that is not followed by '{' or
+// '$' is kept as-is. It is an error to reference a variable that is not set
+// and has no default.
+func expandEnv(value string, lookupEnv func(string) (string, bool)) (string, error) {
+	var expanded strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			expanded.WriteByte(value[i])
+			continue
+		}
+
+		switch value[i+1] {
+		case '$':
+			expanded.WriteByte('$')
+			i++
+
+		case '{':
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference %q: add a closing '}' or escape '$' as '$$'", value[i:])
+			}
+
+			reference := value[i+2 : i+2+end]
+			name, fallback, hasDefault := strings.Cut(reference, ":-")
+			if !variableNamePattern.MatchString(name) {
+				return "", fmt.Errorf("invalid variable reference ${%s}: variable names may only contain letters, numbers and '_'", reference)
+			}
+
+			variable, ok := lookupEnv(name)
+			switch {
+			case hasDefault && variable == "":
+				expanded.WriteString(fallback)
+			case ok:
+				expanded.WriteString(variable)
+			default:
+				return "", fmt.Errorf("undefined variable %s: set it at build time or give a default with ${%s:-default}", name, name)
+			}
+
+			i += 2 + end
+
+		default:
+			expanded.WriteByte('$')
+		}
+	}
+
+	return expanded.String(), nil
+}
+
+// expandTargets returns the given targets with their environment variables
+// expanded by expandEnv. Errors name the kind of target, e.g. "profile", and
+// its name.
+func expandTargets(targets map[string]string, kind string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	expanded := map[string]string{}
+	for name, target := range targets {
+		var err error
+		expanded[name], err = expandEnv(target, lookupEnv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand the target of %s %q: %w", kind, name, err)
+		}
+	}
+
+	return expanded, nil
+}
+
+// expand returns the given target, configured in origin, with its environment
+// variables expanded when BP_POETRY_RUN_EXPAND_ENV is enabled.
+func (r Resolver) expand(origin, target string) (string, error) {
+	enabled, err := r.lookupBoolEnv("BP_POETRY_RUN_EXPAND_ENV")
+	if err != nil || !enabled {
+		return target, err
+	}
+
+	expanded, err := expandEnv(target, r.lookupEnv)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %s: %w", origin, err)
+	}
+
+	return expanded, nil
+}
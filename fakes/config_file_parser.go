@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+)
+
+type ConfigFileParser struct {
+	ParseCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			String string
+		}
+		Returns struct {
+			PoetryConfig poetryrun.PoetryConfig
+			Error        error
+		}
+		Stub func(string) (poetryrun.PoetryConfig, error)
+	}
+}
+
+func (f *ConfigFileParser) Parse(param1 string) (poetryrun.PoetryConfig, error) {
+	f.ParseCall.mutex.Lock()
+	defer f.ParseCall.mutex.Unlock()
+	f.ParseCall.CallCount++
+	f.ParseCall.Receives.String = param1
+	if f.ParseCall.Stub != nil {
+		return f.ParseCall.Stub(param1)
+	}
+	return f.ParseCall.Returns.PoetryConfig, f.ParseCall.Returns.Error
+}
@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+)
+
+type LockFileParser struct {
+	ParseCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			String string
+		}
+		Returns struct {
+			PoetryLock poetryrun.PoetryLock
+			Error      error
+		}
+		Stub func(string) (poetryrun.PoetryLock, error)
+	}
+}
+
+func (f *LockFileParser) Parse(param1 string) (poetryrun.PoetryLock, error) {
+	f.ParseCall.mutex.Lock()
+	defer f.ParseCall.mutex.Unlock()
+	f.ParseCall.CallCount++
+	f.ParseCall.Receives.String = param1
+	if f.ParseCall.Stub != nil {
+		return f.ParseCall.Stub(param1)
+	}
+	return f.ParseCall.Returns.PoetryLock, f.ParseCall.Returns.Error
+}
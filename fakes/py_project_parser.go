@@ -1,6 +1,10 @@
 package fakes
 
-import "sync"
+import (
+	"sync"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+)
 
 type PyProjectParser struct {
 	ParseCall struct {
@@ -10,14 +14,14 @@ type PyProjectParser struct {
 			String string
 		}
 		Returns struct {
-			String string
-			Error  error
+			PyProject poetryrun.PyProject
+			Error     error
 		}
-		Stub func(string) (string, error)
+		Stub func(string) (poetryrun.PyProject, error)
 	}
 }
 
-func (f *PyProjectParser) Parse(param1 string) (string, error) {
+func (f *PyProjectParser) Parse(param1 string) (poetryrun.PyProject, error) {
 	f.ParseCall.mutex.Lock()
 	defer f.ParseCall.mutex.Unlock()
 	f.ParseCall.CallCount++
@@ -25,5 +29,5 @@ func (f *PyProjectParser) Parse(param1 string) (string, error) {
 	if f.ParseCall.Stub != nil {
 		return f.ParseCall.Stub(param1)
 	}
-	return f.ParseCall.Returns.String, f.ParseCall.Returns.Error
+	return f.ParseCall.Returns.PyProject, f.ParseCall.Returns.Error
 }
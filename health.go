@@ -0,0 +1,67 @@
+package poetryrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// healthConfig returns the configuration of the 'health' process. The
+// BP_POETRY_RUN_HEALTH_TARGET, BP_POETRY_RUN_HEALTH_HTTP_PATH and
+// BP_POETRY_RUN_HEALTH_TCP_PORT environment variables replace the
+// configuration in pyproject.toml when any of them is set. It also returns
+// where the configuration came from.
+func (r Resolver) healthConfig(configured HealthConfig) (HealthConfig, TargetSource, error) {
+	target, hasTarget := r.lookupEnv("BP_POETRY_RUN_HEALTH_TARGET")
+	path, hasPath := r.lookupEnv("BP_POETRY_RUN_HEALTH_HTTP_PATH")
+	port, hasPort := r.lookupEnv("BP_POETRY_RUN_HEALTH_TCP_PORT")
+
+	config, source := configured, ConfigurationSource
+	if hasTarget || hasPath || hasPort {
+		config, source = HealthConfig{Target: target, HTTPPath: path}, EnvironmentSource
+
+		if port != "" {
+			var err error
+			config.TCPPort, err = strconv.Atoi(port)
+			if err != nil {
+				return HealthConfig{}, "", fmt.Errorf("failed to parse BP_POETRY_RUN_HEALTH_TCP_PORT value %s: %w", port, err)
+			}
+		}
+	}
+
+	config.Target = strings.TrimSpace(config.Target)
+
+	var set []string
+	if config.Target != "" {
+		set = append(set, "a target")
+	}
+
+	if config.HTTPPath != "" {
+		if !strings.HasPrefix(config.HTTPPath, "/") {
+			return HealthConfig{}, "", fmt.Errorf("invalid health check: HTTP path %q must start with '/'", config.HTTPPath)
+		}
+		set = append(set, "an HTTP path")
+	}
+
+	if config.TCPPort != 0 {
+		if config.TCPPort < 1 || config.TCPPort > 65535 {
+			return HealthConfig{}, "", fmt.Errorf("invalid health check: TCP port %d must be between 1 and 65535", config.TCPPort)
+		}
+		set = append(set, "a TCP port")
+	}
+
+	if len(set) > 1 {
+		return HealthConfig{}, "", fmt.Errorf("invalid health check: only one of a target, an HTTP path or a TCP port may be set, found %s", strings.Join(set, " and "))
+	}
+
+	return config, source, nil
+}
+
+// checkArgs returns the arguments of the built-in health check binary.
+func (c HealthConfig) checkArgs() []string {
+	if c.HTTPPath != "" {
+		return []string{"--http", c.HTTPPath}
+	}
+
+	return []string{"--tcp", strconv.Itoa(c.TCPPort)}
+}
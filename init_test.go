@@ -11,6 +11,11 @@ func TestUnitPoetryRun(t *testing.T) {
 	suite := spec.New("poetryrun", spec.Report(report.Terminal{}))
 	suite("Detect", testDetect)
 	suite("Build", testBuild)
+	suite("PyProject", testPyProject)
 	suite("PyProjectConfigParser", testPyProjectConfigParser)
+	suite("Resolver", testResolver)
+	suite("PoetryLockParser", testPoetryLockParser)
+	suite("PoetryConfigParser", testPoetryConfigParser)
+	suite("Report", testReport)
 	suite.Run(t)
 }
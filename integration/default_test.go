@@ -66,6 +66,12 @@ func testDefault(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(logs).To(ContainLines(
 				MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, buildpackInfo.Buildpack.Name)),
+			))
+			Expect(logs).To(ContainLines(
+				"  Recording the Python runtime",
+				MatchRegexp(`^    Venv \S+ created with CPython \d+\.\d+\.\d+$`),
+			))
+			Expect(logs).To(ContainLines(
 				"  Assigning launch processes:",
 				"    web (default): poetry run my script",
 			))
@@ -105,6 +111,8 @@ func testDefault(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(logs).To(ContainLines(
 				MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, buildpackInfo.Buildpack.Name)),
+			))
+			Expect(logs).To(ContainLines(
 				"  Assigning launch processes:",
 				"    reload-web (default): watchexec --restart --watch /workspace --shell none -- poetry run my script",
 				"    web:                  poetry run my script",
@@ -74,6 +74,8 @@ func testRunTargets(t *testing.T, context spec.G, it spec.S) {
 
 				Expect(logs).To(ContainLines(
 					MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, buildpackInfo.Buildpack.Name)),
+				))
+				Expect(logs).To(ContainLines(
 					"  Assigning launch processes:",
 					"    web (default): poetry run python -V",
 				))
@@ -90,6 +92,40 @@ func testRunTargets(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when the default args are overridden at docker run", func() {
+			it("replaces the args of the target", func() {
+				var err error
+				var logs fmt.Stringer
+
+				image, logs, err = pack.WithNoColor().Build.
+					WithPullPolicy("never").
+					WithBuildpacks(
+						settings.Buildpacks.CPython.Online,
+						settings.Buildpacks.Pip.Online,
+						settings.Buildpacks.Poetry.Online,
+						settings.Buildpacks.PoetryInstall.Online,
+						settings.Buildpacks.PoetryRun.Online,
+						settings.Buildpacks.BuildPlan.Online,
+					).
+					WithEnv(map[string]string{
+						"BP_POETRY_RUN_TARGET": "python -V",
+					}).
+					Execute(name, source)
+				Expect(err).ToNot(HaveOccurred(), logs.String)
+
+				container, err = docker.Container.Run.
+					WithCommandArgs([]string{"-c", "print('overridden args')"}).
+					Execute(image.ID)
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(func() string {
+					cLogs, err := docker.Container.Logs.Execute(container.ID)
+					Expect(err).NotTo(HaveOccurred())
+					return cLogs.String()
+				}).Should(ContainSubstring("overridden args"))
+			})
+		})
+
 		context("when BP_POETRY_RUN_TARGET is set to a script key", func() {
 			it("builds and runs successfully", func() {
 				var err error
@@ -113,6 +149,8 @@ func testRunTargets(t *testing.T, context spec.G, it spec.S) {
 
 				Expect(logs).To(ContainLines(
 					MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, buildpackInfo.Buildpack.Name)),
+				))
+				Expect(logs).To(ContainLines(
 					"  Assigning launch processes:",
 					"    web (default): poetry run working-script-key",
 				))
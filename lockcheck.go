@@ -0,0 +1,112 @@
+package poetryrun
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// LockCheckMode is what Build does when poetry.lock may not match
+// pyproject.toml or the Poetry version, as set by BP_POETRY_RUN_LOCK_CHECK.
+type LockCheckMode string
+
+const (
+	// WarnLockCheck logs the problems as warnings and continues the build.
+	WarnLockCheck LockCheckMode = "warn"
+
+	// FailLockCheck fails the build.
+	FailLockCheck LockCheckMode = "fail"
+
+	// OffLockCheck skips the check.
+	OffLockCheck LockCheckMode = "off"
+)
+
+// lockCheckMode reads BP_POETRY_RUN_LOCK_CHECK, which defaults to warn.
+func (r Resolver) lockCheckMode() (LockCheckMode, error) {
+	value, ok := r.lookupEnv("BP_POETRY_RUN_LOCK_CHECK")
+	if !ok || value == "" {
+		return WarnLockCheck, nil
+	}
+
+	switch mode := LockCheckMode(value); mode {
+	case WarnLockCheck, FailLockCheck, OffLockCheck:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("invalid BP_POETRY_RUN_LOCK_CHECK value %s: must be one of warn, fail or off", value)
+}
+
+// lockFileProblems returns why the given poetry.lock may not match the venv
+// that Poetry installs from pyproject.toml: a content-hash that differs from
+// that of pyproject.toml, or a lock-version that the given Poetry version
+// cannot read. Checks that lack the data they need, e.g. a Poetry version,
+// are skipped.
+func lockFileProblems(pyProject PyProject, lock PoetryLock, poetryVersion string) []string {
+	var problems []string
+
+	if lock.Metadata.ContentHash != "" && pyProject.ContentHash != "" && lock.Metadata.ContentHash != pyProject.ContentHash {
+		problems = append(problems, fmt.Sprintf("poetry.lock is out of date: its content-hash %.12s does not match %.12s of pyproject.toml, run 'poetry lock' to update it", lock.Metadata.ContentHash, pyProject.ContentHash))
+	}
+
+	lockMajor, lockMinor, ok := parseMajorMinor(lock.Metadata.LockVersion)
+	if !ok {
+		return problems
+	}
+
+	supported, ok := supportedLockVersion(poetryVersion)
+	if !ok {
+		return problems
+	}
+
+	supportedMajor, supportedMinor, _ := parseMajorMinor(supported)
+	switch {
+	case lockMajor > supportedMajor:
+		problems = append(problems, fmt.Sprintf("poetry.lock has lock-version %s, which Poetry %s cannot read: it supports lock-version %s and older", lock.Metadata.LockVersion, poetryVersion, supported))
+	case lockMajor == supportedMajor && lockMinor > supportedMinor:
+		problems = append(problems, fmt.Sprintf("poetry.lock has lock-version %s, which is newer than the lock-version %s of Poetry %s and may not be compatible", lock.Metadata.LockVersion, supported, poetryVersion))
+	}
+
+	return problems
+}
+
+// supportedLockVersion returns the newest lock-version that the given Poetry
+// version writes and reads.
+func supportedLockVersion(poetryVersion string) (string, bool) {
+	major, minor, ok := parseMajorMinor(poetryVersion)
+	switch {
+	case !ok:
+		return "", false
+	case major >= 2:
+		return "2.1", true
+	case major == 1 && minor >= 3:
+		return "2.0", true
+	default:
+		return "1.1", true
+	}
+}
+
+// poetryVersion returns the version of Poetry set with BP_POETRY_VERSION, if
+// any. The poetry buildpack does not record the version it installs in the
+// build plan, so without it the lock-version check is skipped.
+func (r Resolver) poetryVersion() string {
+	version, _ := r.lookupEnv("BP_POETRY_VERSION")
+	return version
+}
+
+var majorMinorPattern = regexp.MustCompile(`^\D*(\d+)(?:\.(\d+))?`)
+
+// parseMajorMinor returns the major and minor version of a version or version
+// constraint, e.g. "2.1", "1.8.3" or "~1.8".
+func parseMajorMinor(version string) (major, minor int, ok bool) {
+	match := majorMinorPattern.FindStringSubmatch(version)
+	if match == nil {
+		return 0, 0, false
+	}
+
+	major, _ = strconv.Atoi(match[1])
+	if match[2] != "" {
+		minor, _ = strconv.Atoi(match[2])
+	}
+
+	return major, minor, true
+}
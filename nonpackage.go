@@ -0,0 +1,50 @@
+package poetryrun
+
+import (
+	"fmt"
+)
+
+// nonPackageTarget translates a script target into the equivalent python
+// invocation, since Poetry does not install the scripts of a project in
+// non-package mode. A script that references a module runs with
+// 'python -m <module>', and one that references a callable runs with
+// 'python -c <code>'. File scripts cannot be translated. Other targets are
+// returned as-is.
+func nonPackageTarget(target Target, scripts map[string]Script) (Target, error) {
+	if target.Kind != ScriptTarget {
+		return target, nil
+	}
+
+	command, args := target.Split()
+	script := scripts[command[0]]
+
+	if script.Type == FileScript {
+		return Target{}, fmt.Errorf("failed to run script %q: pyproject.toml sets package-mode = false, so Poetry does not install scripts, and the file script %s cannot be translated into a python invocation: set BP_POETRY_RUN_TARGET to the command that runs it", script.Name, script.Reference)
+	}
+
+	pythonArgs := script.PythonArgs()
+
+	kind := ModuleTarget
+	if pythonArgs[0] == "-c" {
+		kind = CallableTarget
+	}
+
+	return Target{
+		Kind:        kind,
+		Argv:        append(append([]string{"python"}, pythonArgs...), args...),
+		Source:      target.Source,
+		Explanation: target.Explanation,
+	}, nil
+}
+
+// processTarget returns the target of a process configured with the given
+// argv, translated with nonPackageTarget when the project is in non-package
+// mode.
+func processTarget(argv []string, pyProject PyProject) (Target, error) {
+	target := Target{Kind: targetKind(argv, pyProject), Argv: argv}
+	if !pyProject.NonPackageMode {
+		return target, nil
+	}
+
+	return nonPackageTarget(target, pyProject.Scripts)
+}
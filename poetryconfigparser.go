@@ -0,0 +1,106 @@
+package poetryrun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PoetryConfig is the subset of a project-local poetry.toml file that this
+// buildpack cares about.
+type PoetryConfig struct {
+	Virtualenvs PoetryVirtualenvsConfig `toml:"virtualenvs"`
+}
+
+// PoetryVirtualenvsConfig is the [virtualenvs] table of a poetry.toml file.
+// Settings that are not set are nil.
+type PoetryVirtualenvsConfig struct {
+	Create    *bool   `toml:"create"`
+	InProject *bool   `toml:"in-project"`
+	Path      *string `toml:"path"`
+	Options   struct {
+		SystemSitePackages *bool `toml:"system-site-packages"`
+	} `toml:"options"`
+}
+
+// PoetryConfigConflict is a poetry.toml setting that conflicts with the venv
+// built by the poetry-install buildpack.
+type PoetryConfigConflict struct {
+	// Setting is the key of the setting, e.g. virtualenvs.in-project.
+	Setting string
+
+	// Warning explains the conflict and how it is resolved.
+	Warning string
+
+	// Env and Value are the launch environment variable that overrides the
+	// setting, if it can be overridden.
+	Env   string
+	Value string
+}
+
+// Conflicts returns the settings that would make 'poetry run' resolve a
+// different environment than the venv built by the poetry-install buildpack.
+func (c PoetryConfig) Conflicts() []PoetryConfigConflict {
+	var conflicts []PoetryConfigConflict
+
+	if c.Virtualenvs.Create != nil && !*c.Virtualenvs.Create {
+		conflicts = append(conflicts, PoetryConfigConflict{
+			Setting: "virtualenvs.create",
+			Warning: "poetry.toml sets virtualenvs.create = false, so poetry run would use the system Python instead of the venv: overriding it with POETRY_VIRTUALENVS_CREATE=true at launch",
+			Env:     "POETRY_VIRTUALENVS_CREATE",
+			Value:   "true",
+		})
+	}
+
+	if c.Virtualenvs.InProject != nil && *c.Virtualenvs.InProject {
+		conflicts = append(conflicts, PoetryConfigConflict{
+			Setting: "virtualenvs.in-project",
+			Warning: "poetry.toml sets virtualenvs.in-project = true, but the venv is not built in the project: overriding it with POETRY_VIRTUALENVS_IN_PROJECT=false at launch",
+			Env:     "POETRY_VIRTUALENVS_IN_PROJECT",
+			Value:   "false",
+		})
+	}
+
+	if c.Virtualenvs.Path != nil {
+		conflicts = append(conflicts, PoetryConfigConflict{
+			Setting: "virtualenvs.path",
+			Warning: fmt.Sprintf("poetry.toml sets virtualenvs.path = %q, which may not hold the venv built by the poetry-install buildpack: remove it from poetry.toml", *c.Virtualenvs.Path),
+		})
+	}
+
+	if c.Virtualenvs.Options.SystemSitePackages != nil && *c.Virtualenvs.Options.SystemSitePackages {
+		conflicts = append(conflicts, PoetryConfigConflict{
+			Setting: "virtualenvs.options.system-site-packages",
+			Warning: "poetry.toml sets virtualenvs.options.system-site-packages = true, so packages of the Python in the image are visible next to the locked dependencies: remove it from poetry.toml",
+		})
+	}
+
+	return conflicts
+}
+
+type PoetryConfigParser struct {
+}
+
+func NewPoetryConfigParser() PoetryConfigParser {
+	return PoetryConfigParser{}
+}
+
+// Parse returns the contents of the given poetry.toml file
+// If there is no file, Parse returns an empty PoetryConfig and a nil error
+// If there is an error reading or decoding the file, Parse returns an error
+func (p PoetryConfigParser) Parse(path string) (PoetryConfig, error) {
+	var config PoetryConfig
+
+	_, err := toml.DecodeFile(path, &config)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return PoetryConfig{}, nil
+		}
+
+		return PoetryConfig{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return config, nil
+}
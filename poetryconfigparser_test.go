@@ -0,0 +1,107 @@
+package poetryrun_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPoetryConfigParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect     = NewWithT(t).Expect
+		parser     poetryrun.PoetryConfigParser
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		parser = poetryrun.NewPoetryConfigParser()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("Parse", func() {
+		it("returns the settings that conflict with the venv", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "poetry.toml"), []byte(`
+virtualenvs.create = false
+
+[virtualenvs]
+in-project = true
+path = "/some/venvs"
+
+[virtualenvs.options]
+system-site-packages = true
+
+[repositories.internal]
+url = "https://pypi.example.com/simple"
+`), 0644)).To(Succeed())
+
+			config, err := parser.Parse(filepath.Join(workingDir, "poetry.toml"))
+			Expect(err).NotTo(HaveOccurred())
+
+			conflicts := config.Conflicts()
+			Expect(conflicts).To(HaveLen(4))
+			Expect(conflicts[0]).To(Equal(poetryrun.PoetryConfigConflict{
+				Setting: "virtualenvs.create",
+				Warning: "poetry.toml sets virtualenvs.create = false, so poetry run would use the system Python instead of the venv: overriding it with POETRY_VIRTUALENVS_CREATE=true at launch",
+				Env:     "POETRY_VIRTUALENVS_CREATE",
+				Value:   "true",
+			}))
+			Expect(conflicts[1]).To(Equal(poetryrun.PoetryConfigConflict{
+				Setting: "virtualenvs.in-project",
+				Warning: "poetry.toml sets virtualenvs.in-project = true, but the venv is not built in the project: overriding it with POETRY_VIRTUALENVS_IN_PROJECT=false at launch",
+				Env:     "POETRY_VIRTUALENVS_IN_PROJECT",
+				Value:   "false",
+			}))
+			Expect(conflicts[2]).To(Equal(poetryrun.PoetryConfigConflict{
+				Setting: "virtualenvs.path",
+				Warning: `poetry.toml sets virtualenvs.path = "/some/venvs", which may not hold the venv built by the poetry-install buildpack: remove it from poetry.toml`,
+			}))
+			Expect(conflicts[3].Setting).To(Equal("virtualenvs.options.system-site-packages"))
+			Expect(conflicts[3].Env).To(BeEmpty())
+		})
+
+		it("returns no conflicts for compatible settings", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "poetry.toml"), []byte(`
+[virtualenvs]
+create = true
+in-project = false
+`), 0644)).To(Succeed())
+
+			config, err := parser.Parse(filepath.Join(workingDir, "poetry.toml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Conflicts()).To(BeEmpty())
+		})
+
+		context("when there is no poetry.toml file", func() {
+			it("returns an empty config", func() {
+				config, err := parser.Parse(filepath.Join(workingDir, "poetry.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(poetryrun.PoetryConfig{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the poetry.toml is not valid TOML", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "poetry.toml"), []byte("[virtualenvs"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.Parse(filepath.Join(workingDir, "poetry.toml"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse %s", filepath.Join(workingDir, "poetry.toml"))))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package poetryrun
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PoetryLock is the subset of a poetry.lock file that this buildpack cares
+// about.
+type PoetryLock struct {
+	Packages []PoetryLockPackage `toml:"package"`
+	Metadata PoetryLockMetadata  `toml:"metadata"`
+}
+
+// PoetryLockMetadata is the [metadata] table of a poetry.lock file.
+type PoetryLockMetadata struct {
+	// LockVersion is the version of the lock file format, e.g. "2.1".
+	LockVersion string `toml:"lock-version"`
+
+	// ContentHash is the hash of the pyproject.toml content that was locked.
+	ContentHash string `toml:"content-hash"`
+}
+
+// PoetryLockPackage is a single [[package]] entry of a poetry.lock file.
+type PoetryLockPackage struct {
+	Name    string `toml:"name"`
+	Version string `toml:"version"`
+}
+
+// HasPackage reports whether the lock file pins a package with the given
+// name. Names are compared using the normalization rules from PEP 503.
+func (l PoetryLock) HasPackage(name string) bool {
+	for _, pkg := range l.Packages {
+		if normalizePackageName(pkg.Name) == normalizePackageName(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type PoetryLockParser struct {
+}
+
+func NewPoetryLockParser() PoetryLockParser {
+	return PoetryLockParser{}
+}
+
+// Parse returns the contents of the given poetry.lock file
+// If there is no file, Parse returns an empty PoetryLock and a nil error
+// If there is an error reading the file, Parse returns an error
+func (p PoetryLockParser) Parse(filepath string) (PoetryLock, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return PoetryLock{}, nil
+		}
+
+		return PoetryLock{}, err
+	}
+	defer file.Close()
+
+	var poetryLock PoetryLock
+
+	_, err = toml.NewDecoder(file).Decode(&poetryLock)
+	if err != nil {
+		return PoetryLock{}, err
+	}
+
+	return poetryLock, nil
+}
+
+var packageNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+func normalizePackageName(name string) string {
+	return packageNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
@@ -0,0 +1,110 @@
+package poetryrun_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPoetryLockParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect     = NewWithT(t).Expect
+		parser     poetryrun.PoetryLockParser
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		contents := `
+[[package]]
+name = "Flask"
+version = "3.0.0"
+
+[[package]]
+name = "debugpy"
+version = "1.8.0"
+
+[metadata]
+lock-version = "2.1"
+content-hash = "some-content-hash"
+`
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "poetry.lock"), []byte(contents), 0644)).To(Succeed())
+
+		parser = poetryrun.NewPoetryLockParser()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("Parse", func() {
+		it("returns the locked packages", func() {
+			poetryLock, err := parser.Parse(filepath.Join(workingDir, "poetry.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(poetryLock.Packages).To(Equal([]poetryrun.PoetryLockPackage{
+				{Name: "Flask", Version: "3.0.0"},
+				{Name: "debugpy", Version: "1.8.0"},
+			}))
+		})
+
+		it("returns the lock metadata", func() {
+			poetryLock, err := parser.Parse(filepath.Join(workingDir, "poetry.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(poetryLock.Metadata).To(Equal(poetryrun.PoetryLockMetadata{
+				LockVersion: "2.1",
+				ContentHash: "some-content-hash",
+			}))
+		})
+
+		context("when there is no poetry.lock file", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "poetry.lock"))).To(Succeed())
+			})
+
+			it("returns an empty lock without error", func() {
+				poetryLock, err := parser.Parse(filepath.Join(workingDir, "poetry.lock"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(poetryLock).To(Equal(poetryrun.PoetryLock{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the poetry.lock is not valid TOML", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "poetry.lock"), []byte("%%%"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.Parse(filepath.Join(workingDir, "poetry.lock"))
+					Expect(err).To(MatchError(ContainSubstring("toml:")))
+				})
+			})
+		})
+	})
+
+	context("HasPackage", func() {
+		it("matches package names using PEP 503 normalization", func() {
+			poetryLock := poetryrun.PoetryLock{
+				Packages: []poetryrun.PoetryLockPackage{
+					{Name: "Some_Package.Name"},
+				},
+			}
+
+			Expect(poetryLock.HasPackage("some-package-name")).To(BeTrue())
+			Expect(poetryLock.HasPackage("SOME.PACKAGE_NAME")).To(BeTrue())
+			Expect(poetryLock.HasPackage("other-package")).To(BeFalse())
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package poetryrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// poetryGlobalOptions maps the global options of Poetry that may be placed
+// before 'run' to whether they take a value. Options that make Poetry exit
+// without running the target, such as --help and --version, are left out.
+var poetryGlobalOptions = map[string]bool{
+	"--quiet":          false,
+	"-q":               false,
+	"--verbose":        false,
+	"-v":               false,
+	"-vv":              false,
+	"-vvv":             false,
+	"--ansi":           false,
+	"--no-ansi":        false,
+	"--no-interaction": false,
+	"-n":               false,
+	"--no-plugins":     false,
+	"--no-cache":       false,
+	"--directory":      true,
+	"-C":               true,
+	"--project":        true,
+	"-P":               true,
+}
+
+// poetryOptions returns the global options of Poetry from
+// BP_POETRY_RUN_POETRY_OPTIONS or, when it is not set, from the given
+// poetry-options of [tool.paketo.poetry-run]. Each option must be one of
+// poetryGlobalOptions; options that take a value accept it as the next
+// argument or after '='.
+func (r Resolver) poetryOptions(configured string) ([]string, error) {
+	origin := "[tool.paketo.poetry-run] poetry-options"
+	if value, ok := r.lookupEnv("BP_POETRY_RUN_POETRY_OPTIONS"); ok {
+		origin = "BP_POETRY_RUN_POETRY_OPTIONS"
+		configured = value
+	}
+
+	args := strings.Fields(configured)
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+
+		takesValue, ok := poetryGlobalOptions[name]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("invalid %s value %s: %s is not a supported global option of poetry, use --quiet, --verbose, --ansi, --no-ansi, --no-interaction, --no-plugins, --no-cache, --directory or --project", origin, configured, name)
+		case !takesValue && hasValue:
+			return nil, fmt.Errorf("invalid %s value %s: %s does not take a value", origin, configured, name)
+		case takesValue && !hasValue:
+			if i+1 == len(args) || strings.HasPrefix(args[i+1], "-") {
+				return nil, fmt.Errorf("invalid %s value %s: %s requires a value", origin, configured, name)
+			}
+			i++
+		case takesValue && value == "":
+			return nil, fmt.Errorf("invalid %s value %s: %s requires a value", origin, configured, name)
+		}
+	}
+
+	return args, nil
+}
+
+// poetryCommand returns the command that runs a target through Poetry, with
+// the given global options before 'run'.
+func poetryCommand(options []string) []string {
+	return append(append([]string{"poetry"}, options...), "run")
+}
+
+// poetryInstallCommand returns the command that syncs the venv with the lock
+// file, with the given global options before 'install'.
+func poetryInstallCommand(options []string) []string {
+	return append(append([]string{"poetry"}, options...), "install", "--sync")
+}
+
+// poetryProjectDir returns the directory of the project that Poetry runs with
+// the given global options: the app directory, changed by --directory and
+// then by --project, both relative to the directory before them.
+func poetryProjectDir(workingDir string, options []string) string {
+	directory, project := workingDir, ""
+	for i := 0; i < len(options); i++ {
+		name, value, hasValue := strings.Cut(options[i], "=")
+		if !poetryGlobalOptions[name] {
+			continue
+		}
+
+		if !hasValue && i+1 < len(options) {
+			i++
+			value = options[i]
+		}
+
+		switch name {
+		case "--directory", "-C":
+			directory = resolvePath(workingDir, value)
+		case "--project", "-P":
+			project = value
+		}
+	}
+
+	if project == "" {
+		return directory
+	}
+
+	return resolvePath(directory, project)
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// withPoetryOptions places the given global options before 'run' in the
+// command of a process that runs 'poetry run', either directly, wrapped in
+// another command such as watchexec, or in a bash script that execs it.
+func withPoetryOptions(process packit.DirectProcess, options []string) packit.DirectProcess {
+	if len(options) == 0 {
+		return process
+	}
+
+	for i := 0; i+1 < len(process.Command); i++ {
+		if process.Command[i] == "poetry" && process.Command[i+1] == "run" {
+			command := append(append([]string{}, process.Command[:i]...), poetryCommand(options)...)
+			process.Command = append(command, process.Command[i+2:]...)
+			return process
+		}
+	}
+
+	if len(process.Command) >= 3 && process.Command[0] == "bash" && process.Command[1] == "-c" {
+		var quoted []string
+		for _, option := range options {
+			quoted = append(quoted, shellQuote(option))
+		}
+
+		command := append([]string{}, process.Command...)
+		command[2] = strings.Replace(command[2], "exec poetry run ", fmt.Sprintf("exec poetry %s run ", strings.Join(quoted, " ")), 1)
+		process.Command = command
+	}
+
+	return process
+}
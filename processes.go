@@ -0,0 +1,231 @@
+package poetryrun
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// auxiliaryProcessEnvPrefix is the prefix of environment variables that
+// declare auxiliary processes, e.g. BP_POETRY_RUN_PROCESS_RELEASE.
+const auxiliaryProcessEnvPrefix = "BP_POETRY_RUN_PROCESS_"
+
+var processTypePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// auxiliaryTargets merges the auxiliary processes configured in
+// pyproject.toml with those configured through BP_POETRY_RUN_PROCESS_<NAME>
+// environment variables. Environment variables take precedence. The process
+// type for an environment variable is its lowercased <NAME> suffix, with
+// underscores replaced by dashes. It also returns where each target was
+// configured.
+func (r Resolver) auxiliaryTargets(configured map[string]string) (map[string]string, map[string]TargetSource) {
+	return r.mergeEnvTargets(configured, auxiliaryProcessEnvPrefix, func(name string) string {
+		return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	})
+}
+
+// mergeEnvTargets merges the given targets configured in pyproject.toml with
+// those configured through environment variables with the given prefix,
+// keyed by the name that follows the prefix, normalized with normalize.
+// Environment variables take precedence. It also returns where each target
+// was configured.
+func (r Resolver) mergeEnvTargets(configured map[string]string, prefix string, normalize func(string) string) (map[string]string, map[string]TargetSource) {
+	targets := map[string]string{}
+	sources := map[string]TargetSource{}
+	for name, target := range configured {
+		targets[name] = target
+		sources[name] = ConfigurationSource
+	}
+
+	for _, variable := range r.environ() {
+		name, target, _ := strings.Cut(variable, "=")
+		suffix, found := strings.CutPrefix(name, prefix)
+		if !found || suffix == "" {
+			continue
+		}
+
+		targets[normalize(suffix)] = target
+		sources[normalize(suffix)] = EnvironmentSource
+	}
+
+	return targets, sources
+}
+
+// auxiliaryProcesses returns a non-default 'poetry run' process for each of
+// the given targets, sorted by process type. Targets that name a script of the
+// project run like the default process, translated in non-package mode.
+// Process types must be valid CNB process types and must not clash with the
+// processes this buildpack assigns itself.
+func auxiliaryProcesses(targets map[string]string, pyProject PyProject, reserved []packit.DirectProcess) ([]packit.DirectProcess, error) {
+	var processTypes []string
+	for processType := range targets {
+		processTypes = append(processTypes, processType)
+	}
+	sort.Strings(processTypes)
+
+	var processes []packit.DirectProcess
+	for _, processType := range processTypes {
+		if !processTypePattern.MatchString(processType) {
+			return nil, fmt.Errorf("invalid process type %q: process types may only contain letters, numbers, '.', '_' and '-'", processType)
+		}
+
+		if hasProcessType(reserved, processType) {
+			return nil, fmt.Errorf("invalid process type %q: conflicts with a process assigned by this buildpack", processType)
+		}
+
+		argv := strings.Fields(targets[processType])
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("invalid process %q: run target must not be empty", processType)
+		}
+
+		target, err := processTarget(argv, pyProject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process %q: %w", processType, err)
+		}
+
+		processes = append(processes, poetryRunProcess(processType, target, false))
+	}
+
+	return processes, nil
+}
+
+var invalidProcessTypeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// scriptProcessType derives a process type from a script key: the key in
+// lowercase, with every run of characters other than letters and numbers
+// replaced by a dash, e.g. "My script.v2" becomes "my-script-v2".
+func scriptProcessType(key string) string {
+	return strings.Trim(invalidProcessTypeChars.ReplaceAllString(strings.ToLower(key), "-"), "-")
+}
+
+// scriptProcessTypes returns the process type of each script of the project,
+// derived from its key or taken from [tool.paketo.poetry-run.process-types].
+// It returns an error when an override is not a valid process type, when a
+// key has no characters to derive a process type from, or when two scripts
+// map to the same process type.
+func scriptProcessTypes(pyProject PyProject) (map[string]string, error) {
+	processTypes := map[string]string{}
+	scripts := map[string]string{}
+
+	for _, name := range pyProject.ScriptNames() {
+		processType, ok := pyProject.PoetryRun.ProcessTypes[name]
+		switch {
+		case ok && !processTypePattern.MatchString(processType):
+			return nil, fmt.Errorf("invalid process type %q for script %q: process types may only contain letters, numbers, '.', '_' and '-'", processType, name)
+		case !ok:
+			processType = scriptProcessType(name)
+			if processType == "" {
+				return nil, fmt.Errorf("failed to derive a process type from script %q: map it to one under [tool.paketo.poetry-run.process-types]", name)
+			}
+		}
+
+		if other, found := scripts[processType]; found {
+			return nil, fmt.Errorf("scripts %q and %q both map to process type %q: map one of them to another process type under [tool.paketo.poetry-run.process-types]", other, name, processType)
+		}
+
+		processTypes[name] = processType
+		scripts[processType] = name
+	}
+
+	return processTypes, nil
+}
+
+// scriptProcesses returns a non-default 'poetry run' process for each script
+// of the project other than the given selected one, sorted by name, with the
+// process type from scriptProcessTypes. Scripts whose process types clash
+// with the given reserved processes, and scripts that cannot run in
+// non-package mode, are skipped; the reasons are returned as warnings.
+func scriptProcesses(pyProject PyProject, selected string, reserved []packit.DirectProcess) ([]packit.DirectProcess, []string, error) {
+	processTypes, err := scriptProcessTypes(pyProject)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var processes []packit.DirectProcess
+	var warnings []string
+
+	for _, name := range pyProject.ScriptNames() {
+		if name == selected {
+			continue
+		}
+
+		processType := processTypes[name]
+		if hasProcessType(reserved, processType) {
+			warnings = append(warnings, fmt.Sprintf("script %q is not assigned process type %q: conflicts with another process", name, processType))
+			continue
+		}
+
+		target := Target{Kind: ScriptTarget, Argv: []string{name}}
+		if pyProject.NonPackageMode {
+			var err error
+			target, err = nonPackageTarget(target, pyProject.Scripts)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("script %q is not assigned a process type: %s", name, err))
+				continue
+			}
+		}
+
+		processes = append(processes, poetryRunProcess(processType, target, false))
+	}
+
+	return processes, warnings, nil
+}
+
+func hasProcessType(processes []packit.DirectProcess, processType string) bool {
+	for _, process := range processes {
+		if process.Type == processType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// poetryRunProcess returns a direct process that runs the given target with
+// 'poetry run'. The command of the process is fixed to 'poetry run' and the
+// target's command, while the remaining arguments of the target are the
+// default args of the process, which can be overridden at launch.
+func poetryRunProcess(processType string, target Target, isDefault bool) packit.DirectProcess {
+	command, args := target.Split()
+
+	return packit.DirectProcess{
+		Type:    processType,
+		Command: append([]string{"poetry", "run"}, command...),
+		Args:    args,
+		Default: isDefault,
+	}
+}
+
+// reloadableProcesses hands the given process to the reloader and returns the
+// resulting non-reloadable and reloadable processes. The reloader only knows
+// about API 0.8 processes, so only the fixed command is handed over; the
+// default args are carried over as-is so that they can still be overridden
+// at launch. The graceful restart settings of the spec are applied to the
+// reloadable process when it runs watchexec.
+func reloadableProcesses(reloader Reloader, process packit.DirectProcess, spec ReloadSpec) (nonReloadable, reloadable packit.DirectProcess) {
+	nonReloadableProcess, reloadableProcess := reloader.TransformReloadableProcesses(packit.Process{
+		Type:    process.Type,
+		Command: process.Command[0],
+		Args:    process.Command[1:],
+		Default: process.Default,
+		Direct:  true,
+	}, spec.ReloadableProcessSpec)
+
+	if reloadableProcess.Command == "watchexec" {
+		reloadableProcess.Args = spec.watchexecArgs(reloadableProcess.Args)
+	}
+
+	return directProcess(nonReloadableProcess, process.Args), directProcess(reloadableProcess, process.Args)
+}
+
+func directProcess(process packit.Process, args []string) packit.DirectProcess {
+	return packit.DirectProcess{
+		Type:    process.Type,
+		Command: append([]string{process.Command}, process.Args...),
+		Args:    args,
+		Default: process.Default,
+	}
+}
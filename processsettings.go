@@ -0,0 +1,114 @@
+package poetryrun
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// ProcessSettings configures a single process type under
+// [tool.paketo.poetry-run.process-settings.<type>].
+type ProcessSettings struct {
+	// WorkingDirectory is the directory the process runs in, relative to the
+	// app directory.
+	WorkingDirectory string `toml:"working-directory"`
+
+	// Env holds environment variables that are only set for the process.
+	Env map[string]string `toml:"env"`
+}
+
+// processSettings returns the settings of each of the given processes, with
+// their working directories resolved against the app directory. The settings
+// of a process type also apply to its reloadable 'reload-<type>' process. It
+// returns an error when a setting names no process, or when a working
+// directory or variable name is invalid.
+func processSettings(workingDir string, configured map[string]ProcessSettings, processes []packit.DirectProcess) (map[string]ProcessSettings, error) {
+	var processTypes []string
+	for processType := range configured {
+		processTypes = append(processTypes, processType)
+	}
+	sort.Strings(processTypes)
+
+	settings := map[string]ProcessSettings{}
+	for _, processType := range processTypes {
+		setting := configured[processType]
+		if !hasProcessType(processes, processType) {
+			return nil, fmt.Errorf("invalid process settings for %q: there is no process of that type", processType)
+		}
+
+		if setting.WorkingDirectory != "" {
+			err := checkWorkingDirectory(workingDir, setting.WorkingDirectory)
+			if err != nil {
+				return nil, fmt.Errorf("invalid working directory %q for process %q: %w", setting.WorkingDirectory, processType, err)
+			}
+			setting.WorkingDirectory = filepath.Join(workingDir, setting.WorkingDirectory)
+		}
+
+		for name := range setting.Env {
+			if !variableNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("invalid environment variable %q for process %q: variable names may only contain letters, numbers and '_'", name, processType)
+			}
+		}
+
+		settings[processType] = setting
+		if hasProcessType(processes, "reload-"+processType) {
+			settings["reload-"+processType] = setting
+		}
+	}
+
+	return settings, nil
+}
+
+func checkWorkingDirectory(workingDir, dir string) error {
+	if filepath.IsAbs(dir) {
+		return fmt.Errorf("must be relative to the app directory")
+	}
+
+	if dir := filepath.Clean(dir); dir == ".." || strings.HasPrefix(dir, "../") {
+		return fmt.Errorf("must be inside the app directory")
+	}
+
+	info, err := os.Stat(filepath.Join(workingDir, dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no such directory in the app")
+		}
+
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+
+	root, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(workingDir, dir))
+	if err != nil {
+		return err
+	}
+
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("must be inside the app directory")
+	}
+
+	return nil
+}
+
+// envNames returns the sorted names of the given environment variables.
+func envNames(env map[string]string) []string {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
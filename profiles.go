@@ -0,0 +1,87 @@
+package poetryrun
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// profileEnvPrefix is the prefix of environment variables that declare run
+// profiles, e.g. BP_POETRY_RUN_TARGET_PROD.
+const profileEnvPrefix = "BP_POETRY_RUN_TARGET_"
+
+// defaultProfile is the name of the profile that runs the resolved target.
+const defaultProfile = "default"
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// profileTargets merges the run profiles configured in pyproject.toml with
+// those configured through BP_POETRY_RUN_TARGET_<PROFILE> environment
+// variables. Environment variables take precedence. Profile names are
+// lowercased, with underscores replaced by dashes. It also returns where
+// each profile was configured.
+func (r Resolver) profileTargets(configured map[string]string) (map[string]string, map[string]TargetSource, error) {
+	normalized := map[string]string{}
+	for name, target := range configured {
+		normalized[profileName(name)] = target
+	}
+
+	targets, sources := r.mergeEnvTargets(normalized, profileEnvPrefix, profileName)
+
+	for _, name := range profileNames(targets) {
+		if name == defaultProfile {
+			return nil, nil, fmt.Errorf("invalid profile %q: the default profile runs the resolved run target", name)
+		}
+
+		if !profileNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("invalid profile %q: profile names may only contain letters, numbers, '-' and '_'", name)
+		}
+
+		argv := strings.Fields(targets[name])
+		if len(argv) == 0 {
+			return nil, nil, fmt.Errorf("invalid profile %q: run target must not be empty", name)
+		}
+
+		targets[name] = strings.Join(argv, " ")
+	}
+
+	return targets, sources, nil
+}
+
+func profileName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// profileEnvVar returns the launch environment variable that holds the run
+// target of the given profile. The select-profile exec.d helper derives the
+// same name from BPL_POETRY_RUN_PROFILE.
+func profileEnvVar(name string) string {
+	return "POETRY_RUN_TARGET_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// profileNames returns the names of the given profiles, sorted.
+func profileNames(profiles map[string]string) []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// profileProcess returns the process that runs the target selected at launch.
+// The target is read from POETRY_RUN_TARGET, which defaults to the resolved
+// target and is replaced by the select-profile exec.d helper. The target is
+// split on whitespace, without globbing; arguments given at launch are
+// appended to it.
+func profileProcess(processType string) packit.DirectProcess {
+	return packit.DirectProcess{
+		Type:    processType,
+		Command: []string{"bash", "-c", `set -f; exec poetry run $POETRY_RUN_TARGET "$@"`, processType},
+		Default: true,
+	}
+}
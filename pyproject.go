@@ -0,0 +1,252 @@
+package poetryrun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PyProject is the model of a pyproject.toml file that this buildpack and
+// the buildpacks embedding it make their decisions from. It covers both the
+// [tool.poetry] layout and the PEP 621 [project] layout used by Poetry 2.
+type PyProject struct {
+	// Path is the location of the parsed pyproject.toml.
+	Path string
+
+	// Exists is false when there is no file at Path.
+	Exists bool
+
+	Name    string
+	Version string
+
+	// PythonConstraint is the supported Python version range, taken from
+	// [project] requires-python or the 'python' dependency under
+	// [tool.poetry.dependencies].
+	PythonConstraint string
+
+	// Scripts holds the scripts defined under [tool.poetry.scripts] and
+	// [project.scripts], keyed by script name.
+	Scripts map[string]Script
+
+	// DependencyGroups holds the declared dependencies keyed by group. The
+	// 'main' group holds the project dependencies and the 'dev' group holds
+	// the legacy [tool.poetry.dev-dependencies].
+	DependencyGroups map[string][]Dependency
+
+	// Packages holds the entries of [tool.poetry.packages].
+	Packages []Package
+
+	// NonPackageMode is true when [tool.poetry] sets package-mode = false,
+	// in which case Poetry installs neither the project nor its scripts.
+	NonPackageMode bool
+
+	// ContentHash is the hash of the dependency relevant content, as Poetry
+	// records it in poetry.lock when locking.
+	ContentHash string
+
+	// Tool holds the raw contents of every [tool.*] section, keyed by tool.
+	Tool map[string]interface{}
+
+	// PoetryRun holds the configuration of this buildpack from
+	// [tool.paketo.poetry-run].
+	PoetryRun PoetryRunConfig
+}
+
+// ScriptType distinguishes the kinds of scripts Poetry can install.
+type ScriptType string
+
+const (
+	// ConsoleScript is a script that calls a Python callable,
+	// e.g. "some.module:main".
+	ConsoleScript ScriptType = "console"
+
+	// FileScript is a script that refers to a file in the project,
+	// e.g. { reference = "bin/run.py", type = "file" }.
+	FileScript ScriptType = "file"
+)
+
+// Script is a single script entry.
+type Script struct {
+	Name string
+	Type ScriptType
+
+	// Reference is the callable (e.g. "some.module:main") of a console script
+	// or the path of a file script.
+	Reference string
+
+	Extras []string
+}
+
+// Module returns the module and callable that a console script references.
+// For a reference without a callable, e.g. "some.module", callable is empty.
+func (s Script) Module() (module, callable string) {
+	module, callable, _ = strings.Cut(s.Reference, ":")
+	return module, callable
+}
+
+// PythonArgs returns the arguments to python that run the script without it
+// being installed: '-m <module>' for a module, '-c <code>' that calls the
+// callable and exits with its result, or the path of a file script.
+func (s Script) PythonArgs() []string {
+	if s.Type == FileScript {
+		return []string{s.Reference}
+	}
+
+	module, callable := s.Module()
+	if callable == "" {
+		return []string{"-m", module}
+	}
+
+	object, _, _ := strings.Cut(callable, ".")
+	code := fmt.Sprintf("import sys; from %s import %s; sys.exit(%s())", module, object, callable)
+
+	return []string{"-c", code}
+}
+
+// Dependency is a single declared dependency.
+type Dependency struct {
+	Name string
+
+	// Constraint is the version constraint, or for PEP 508 requirements the
+	// remainder of the requirement after the name.
+	Constraint string
+
+	// Path is set for path dependencies.
+	Path    string
+	Develop bool
+
+	Optional bool
+}
+
+// Package is a single entry of [tool.poetry.packages].
+type Package struct {
+	Include string
+	From    string
+}
+
+// PoetryRunConfig is the configuration of this buildpack found under
+// [tool.paketo.poetry-run].
+type PoetryRunConfig struct {
+	// Target is the poetry run target of the default process.
+	Target string `toml:"target"`
+
+	// PoetryOptions holds the global options of Poetry placed before 'run',
+	// e.g. "--no-plugins --quiet".
+	PoetryOptions string `toml:"poetry-options"`
+
+	// WorkerModule is the app module that inferred worker processes run.
+	WorkerModule string `toml:"worker-module"`
+
+	// Processes maps auxiliary process types to their poetry run targets.
+	Processes map[string]string `toml:"processes"`
+
+	// Profiles maps profile names to the poetry run targets that can be
+	// selected at launch with BPL_POETRY_RUN_PROFILE.
+	Profiles map[string]string `toml:"profiles"`
+
+	// Health configures the 'health' process.
+	Health HealthConfig `toml:"health"`
+
+	// ProcessTypes maps script keys to the process types that run them,
+	// overriding the process types derived from the keys.
+	ProcessTypes map[string]string `toml:"process-types"`
+
+	// ProcessSettings maps process types to their working directory and
+	// environment.
+	ProcessSettings map[string]ProcessSettings `toml:"process-settings"`
+}
+
+// HealthConfig configures the 'health' process under
+// [tool.paketo.poetry-run.health]. At most one of its fields may be set.
+type HealthConfig struct {
+	// Target is a poetry run target that checks the health of the app.
+	Target string `toml:"target"`
+
+	// HTTPPath is a path on $PORT that responds with a 2xx or 3xx status
+	// when the app is healthy.
+	HTTPPath string `toml:"http-path"`
+
+	// TCPPort is a port that accepts connections when the app is healthy.
+	TCPPort int `toml:"tcp-port"`
+}
+
+// SingleScript returns the name of the only script for Poetry to execute.
+// If there is no pyproject.toml, no script to run, or multiple scripts to
+// run, SingleScript returns a DetectionError describing why.
+func (p PyProject) SingleScript() (string, error) {
+	if !p.Exists {
+		return "", DetectionError{Reason: MissingPyProject, Path: p.Path}
+	}
+
+	names := p.ScriptNames()
+
+	switch len(names) {
+	case 0:
+		return "", DetectionError{Reason: NoScripts, Path: p.Path}
+	case 1:
+		return names[0], nil
+	default:
+		return "", DetectionError{Reason: MultipleScripts, Path: p.Path, Scripts: names}
+	}
+}
+
+// ScriptNames returns the sorted names of all scripts.
+func (p PyProject) ScriptNames() []string {
+	var names []string
+	for name := range p.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// HasDependency reports whether any dependency group declares a dependency
+// with the given name. Names are compared using the normalization rules from
+// PEP 503.
+func (p PyProject) HasDependency(name string) bool {
+	for _, dependencies := range p.DependencyGroups {
+		for _, dependency := range dependencies {
+			if normalizePackageName(dependency.Name) == normalizePackageName(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasMainDependency reports whether the main dependency group, which holds
+// the dependencies installed with the project, declares a dependency with the
+// given name that is not optional. Names are compared using the normalization
+// rules from PEP 503.
+func (p PyProject) HasMainDependency(name string) bool {
+	for _, dependency := range p.DependencyGroups["main"] {
+		if !dependency.Optional && normalizePackageName(dependency.Name) == normalizePackageName(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PathDependencies returns every dependency that refers to a local path,
+// sorted by group and then in declaration order.
+func (p PyProject) PathDependencies() []Dependency {
+	var groups []string
+	for group := range p.DependencyGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	var pathDependencies []Dependency
+	for _, group := range groups {
+		for _, dependency := range p.DependencyGroups[group] {
+			if dependency.Path != "" {
+				pathDependencies = append(pathDependencies, dependency)
+			}
+		}
+	}
+
+	return pathDependencies
+}
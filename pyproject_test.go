@@ -0,0 +1,124 @@
+package poetryrun_test
+
+import (
+	"testing"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPyProject(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("SingleScript", func() {
+		it("returns the key of the only provided script", func() {
+			script, err := poetryrun.PyProject{
+				Path:    "some-path",
+				Exists:  true,
+				Scripts: map[string]poetryrun.Script{"my-script": {Name: "my-script"}},
+			}.SingleScript()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(script).To(Equal("my-script"))
+		})
+
+		context("when there is no pyproject.toml file", func() {
+			it("returns a missing pyproject.toml detection error", func() {
+				_, err := poetryrun.PyProject{Path: "some-path"}.SingleScript()
+				Expect(err).To(MatchError(poetryrun.DetectionError{
+					Reason: poetryrun.MissingPyProject,
+					Path:   "some-path",
+				}))
+			})
+		})
+
+		context("when there are no scripts", func() {
+			it("returns a no scripts detection error", func() {
+				_, err := poetryrun.PyProject{Path: "some-path", Exists: true}.SingleScript()
+				Expect(err).To(MatchError(poetryrun.DetectionError{
+					Reason: poetryrun.NoScripts,
+					Path:   "some-path",
+				}))
+			})
+		})
+
+		context("when there are multiple scripts", func() {
+			it("returns a multiple scripts detection error listing the script keys", func() {
+				_, err := poetryrun.PyProject{
+					Path:   "some-path",
+					Exists: true,
+					Scripts: map[string]poetryrun.Script{
+						"my-script":       {Name: "my-script"},
+						"my-other-script": {Name: "my-other-script"},
+					},
+				}.SingleScript()
+				Expect(err).To(MatchError(poetryrun.DetectionError{
+					Reason:  poetryrun.MultipleScripts,
+					Path:    "some-path",
+					Scripts: []string{"my-other-script", "my-script"},
+				}))
+				Expect(err).To(MatchError("multiple scripts defined under [tool.poetry.scripts] in some-path: my-other-script, my-script"))
+			})
+		})
+	})
+
+	context("HasDependency", func() {
+		it("finds dependencies in any group using PEP 503 normalization", func() {
+			pyProject := poetryrun.PyProject{
+				DependencyGroups: map[string][]poetryrun.Dependency{
+					"main": {{Name: "Flask"}},
+					"dev":  {{Name: "debug_py"}},
+				},
+			}
+
+			Expect(pyProject.HasDependency("flask")).To(BeTrue())
+			Expect(pyProject.HasDependency("debug-py")).To(BeTrue())
+			Expect(pyProject.HasDependency("django")).To(BeFalse())
+		})
+	})
+
+	context("PathDependencies", func() {
+		it("returns the path dependencies sorted by group", func() {
+			pyProject := poetryrun.PyProject{
+				DependencyGroups: map[string][]poetryrun.Dependency{
+					"main": {{Name: "flask"}, {Name: "some-lib", Path: "../some-lib"}},
+					"dev":  {{Name: "some-tool", Path: "../some-tool", Develop: true}},
+				},
+			}
+
+			Expect(pyProject.PathDependencies()).To(Equal([]poetryrun.Dependency{
+				{Name: "some-tool", Path: "../some-tool", Develop: true},
+				{Name: "some-lib", Path: "../some-lib"},
+			}))
+		})
+	})
+
+	context("Script.Module", func() {
+		it("splits the reference into its module and callable", func() {
+			module, callable := poetryrun.Script{Reference: "some.module:app.run"}.Module()
+			Expect(module).To(Equal("some.module"))
+			Expect(callable).To(Equal("app.run"))
+
+			module, callable = poetryrun.Script{Reference: "some.module"}.Module()
+			Expect(module).To(Equal("some.module"))
+			Expect(callable).To(BeEmpty())
+		})
+	})
+
+	context("Script.PythonArgs", func() {
+		it("runs a callable with python -c", func() {
+			Expect(poetryrun.Script{Type: poetryrun.ConsoleScript, Reference: "some.module:app.run"}.PythonArgs()).To(Equal([]string{
+				"-c", "import sys; from some.module import app; sys.exit(app.run())",
+			}))
+		})
+
+		it("runs a module with python -m", func() {
+			Expect(poetryrun.Script{Type: poetryrun.ConsoleScript, Reference: "some.module"}.PythonArgs()).To(Equal([]string{"-m", "some.module"}))
+		})
+
+		it("runs a file script as a file", func() {
+			Expect(poetryrun.Script{Type: poetryrun.FileScript, Reference: "bin/run.py"}.PythonArgs()).To(Equal([]string{"bin/run.py"}))
+		})
+	})
+}
@@ -2,16 +2,127 @@ package poetryrun
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
-type PyProjectConfig struct {
+// DetectionReason describes why a single poetry script could not be found in
+// pyproject.toml.
+type DetectionReason string
+
+const (
+	// MissingPyProject means that there is no pyproject.toml file.
+	MissingPyProject DetectionReason = "missing-pyproject"
+
+	// NoScripts means that pyproject.toml defines no poetry scripts.
+	NoScripts DetectionReason = "no-scripts"
+
+	// MultipleScripts means that pyproject.toml defines more than one poetry
+	// script.
+	MultipleScripts DetectionReason = "multiple-scripts"
+
+	// InvalidTOML means that pyproject.toml could not be parsed as TOML.
+	InvalidTOML DetectionReason = "invalid-toml"
+
+	// AmbiguousScripts means that pyproject.toml defines more than one poetry
+	// script and the preferred script names did not pick exactly one.
+	AmbiguousScripts DetectionReason = "ambiguous-scripts"
+)
+
+// DetectionError is returned when pyproject.toml does not define exactly one
+// poetry script.
+type DetectionError struct {
+	Reason DetectionReason
+	Path   string
+
+	// Scripts holds the sorted keys of the scripts that were found.
+	Scripts []string
+
+	// Preferred holds the preferred script names, in order, and Matches the
+	// scripts that match the first preferred name found, for
+	// AmbiguousScripts.
+	Preferred []string
+	Matches   []string
+
+	// Line and Column locate a TOML syntax error, starting at 1.
+	Line   int
+	Column int
+
+	Err error
+}
+
+func (e DetectionError) Error() string {
+	switch e.Reason {
+	case MissingPyProject:
+		return fmt.Sprintf("no pyproject.toml found at %s", e.Path)
+	case NoScripts:
+		return fmt.Sprintf("no scripts defined under [tool.poetry.scripts] in %s", e.Path)
+	case MultipleScripts:
+		return fmt.Sprintf("multiple scripts defined under [tool.poetry.scripts] in %s: %s", e.Path, strings.Join(e.Scripts, ", "))
+	case InvalidTOML:
+		return fmt.Sprintf("failed to parse %s at line %d, column %d: %s", e.Path, e.Line, e.Column, tomlErrorMessage(e.Err))
+	case AmbiguousScripts:
+		if len(e.Matches) > 0 {
+			return fmt.Sprintf("multiple scripts defined under [tool.poetry.scripts] in %s match the same preferred script name: %s", e.Path, strings.Join(e.Matches, ", "))
+		}
+		return fmt.Sprintf("none of the scripts defined under [tool.poetry.scripts] in %s has a preferred script name (%s): %s", e.Path, strings.Join(e.Preferred, ", "), strings.Join(e.Scripts, ", "))
+	}
+
+	return fmt.Sprintf("failed to find a script in %s", e.Path)
+}
+
+func (e DetectionError) Unwrap() error {
+	return e.Err
+}
+
+// tomlErrorMessage returns the short message of a TOML parse error, without
+// the position information that DetectionError reports separately.
+func tomlErrorMessage(err error) string {
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Message
+	}
+
+	return err.Error()
+}
+
+type pyProjectFile struct {
+	Project struct {
+		Name                 string              `toml:"name"`
+		Version              string              `toml:"version"`
+		RequiresPython       string              `toml:"requires-python"`
+		Dependencies         []string            `toml:"dependencies"`
+		OptionalDependencies map[string][]string `toml:"optional-dependencies"`
+		Scripts              map[string]string   `toml:"scripts"`
+	} `toml:"project"`
+
+	DependencyGroups map[string][]interface{} `toml:"dependency-groups"`
+
 	Tool struct {
 		Poetry struct {
-			Scripts map[string]string `toml:"scripts"`
+			Name            string                 `toml:"name"`
+			Version         string                 `toml:"version"`
+			PackageMode     *bool                  `toml:"package-mode"`
+			Scripts         map[string]interface{} `toml:"scripts"`
+			Dependencies    map[string]interface{} `toml:"dependencies"`
+			DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+			Group           map[string]struct {
+				Dependencies map[string]interface{} `toml:"dependencies"`
+			} `toml:"group"`
+			Packages []struct {
+				Include string `toml:"include"`
+				From    string `toml:"from"`
+			} `toml:"packages"`
 		} `toml:"poetry"`
+
+		Paketo struct {
+			PoetryRun PoetryRunConfig `toml:"poetry-run"`
+		} `toml:"paketo"`
 	} `toml:"tool"`
 }
 
@@ -22,34 +133,269 @@ func NewPyProjectConfigParser() PyProjectConfigParser {
 	return PyProjectConfigParser{}
 }
 
-// Parse returns the name of the script for Poetry to execute
-// If there is no file, no script to run, or multiple scripts to run,
-// Parse returns an empty string and a nil error
+// Parse returns the model of the given pyproject.toml
+// If there is no file, Parse returns a PyProject that does not exist and a nil
+// error
+// If the file is not valid TOML, Parse returns a DetectionError with the
+// position of the syntax error
 // If there is an error reading the file, Parse returns an error
-func (p PyProjectConfigParser) Parse(filepath string) (string, error) {
-	file, err := os.Open(filepath)
+func (p PyProjectConfigParser) Parse(path string) (PyProject, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return "", nil
+			return PyProject{Path: path}, nil
 		}
 
-		return "", err
+		return PyProject{}, err
 	}
 
-	var pyProjectConfig PyProjectConfig
+	var file pyProjectFile
+	_, err = toml.Decode(string(content), &file)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return PyProject{}, DetectionError{
+				Reason: InvalidTOML,
+				Path:   path,
+				Line:   parseErr.Position.Line,
+				Column: parseErr.Position.Col,
+				Err:    parseErr,
+			}
+		}
+
+		return PyProject{}, err
+	}
 
-	_, err = toml.NewDecoder(file).Decode(&pyProjectConfig)
+	var raw map[string]interface{}
+	_, err = toml.Decode(string(content), &raw)
 	if err != nil {
-		return "", err
+		return PyProject{}, err
+	}
+	tool, _ := raw["tool"].(map[string]interface{})
+
+	pyProject := PyProject{
+		Path:             path,
+		Exists:           true,
+		Name:             file.Tool.Poetry.Name,
+		Version:          file.Tool.Poetry.Version,
+		PythonConstraint: file.Project.RequiresPython,
+		Scripts:          map[string]Script{},
+		DependencyGroups: map[string][]Dependency{},
+		Tool:             tool,
+		ContentHash:      contentHash(raw),
+		PoetryRun:        file.Tool.Paketo.PoetryRun,
+		NonPackageMode:   file.Tool.Poetry.PackageMode != nil && !*file.Tool.Poetry.PackageMode,
+	}
+
+	if file.Project.Name != "" {
+		pyProject.Name = file.Project.Name
+	}
+
+	if file.Project.Version != "" {
+		pyProject.Version = file.Project.Version
+	}
+
+	if python, ok := file.Tool.Poetry.Dependencies["python"].(string); ok && pyProject.PythonConstraint == "" {
+		pyProject.PythonConstraint = python
+	}
+
+	for name, reference := range file.Project.Scripts {
+		pyProject.Scripts[name] = Script{Name: name, Type: ConsoleScript, Reference: reference}
 	}
 
-	if len(pyProjectConfig.Tool.Poetry.Scripts) != 1 {
-		return "", nil
+	for name, value := range file.Tool.Poetry.Scripts {
+		script, err := parseScript(name, value)
+		if err != nil {
+			return PyProject{}, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		pyProject.Scripts[name] = script
+	}
+
+	for _, requirement := range file.Project.Dependencies {
+		pyProject.DependencyGroups["main"] = append(pyProject.DependencyGroups["main"], parseRequirement(requirement))
+	}
+
+	for extra, requirements := range file.Project.OptionalDependencies {
+		for _, requirement := range requirements {
+			dependency := parseRequirement(requirement)
+			dependency.Optional = true
+			pyProject.DependencyGroups[extra] = append(pyProject.DependencyGroups[extra], dependency)
+		}
+	}
+
+	for group, entries := range file.DependencyGroups {
+		for _, entry := range entries {
+			if requirement, ok := entry.(string); ok {
+				pyProject.DependencyGroups[group] = append(pyProject.DependencyGroups[group], parseRequirement(requirement))
+			}
+		}
+	}
+
+	poetryGroups := map[string]map[string]interface{}{
+		"main": file.Tool.Poetry.Dependencies,
+		"dev":  file.Tool.Poetry.DevDependencies,
+	}
+	for group, table := range file.Tool.Poetry.Group {
+		if group == "main" || group == "dev" {
+			table.Dependencies = mergeTables(poetryGroups[group], table.Dependencies)
+		}
+		poetryGroups[group] = table.Dependencies
 	}
 
-	for key := range pyProjectConfig.Tool.Poetry.Scripts {
-		return key, nil
+	for group, table := range poetryGroups {
+		dependencies, err := parseDependencies(table)
+		if err != nil {
+			return PyProject{}, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		if len(dependencies) > 0 {
+			pyProject.DependencyGroups[group] = append(pyProject.DependencyGroups[group], dependencies...)
+		}
+	}
+
+	for _, pkg := range file.Tool.Poetry.Packages {
+		pyProject.Packages = append(pyProject.Packages, Package{Include: pkg.Include, From: pkg.From})
+	}
+
+	return pyProject, nil
+}
+
+// parseScript parses a [tool.poetry.scripts] entry, which is either a
+// "module:callable" string, a { callable = "...", extras = [...] } table or a
+// { reference = "...", type = "file" } table. Legacy "module:callable [extra]"
+// strings are also supported.
+func parseScript(name string, value interface{}) (Script, error) {
+	switch v := value.(type) {
+	case string:
+		reference, extras, _ := strings.Cut(v, "[")
+		script := Script{Name: name, Type: ConsoleScript, Reference: strings.TrimSpace(reference)}
+		for _, extra := range strings.Split(strings.TrimSuffix(strings.TrimSpace(extras), "]"), ",") {
+			if extra = strings.TrimSpace(extra); extra != "" {
+				script.Extras = append(script.Extras, extra)
+			}
+		}
+
+		return script, nil
+
+	case map[string]interface{}:
+		script := Script{Name: name, Type: ConsoleScript}
+		if callable, ok := v["callable"].(string); ok {
+			script.Reference = callable
+		}
+
+		if reference, ok := v["reference"].(string); ok {
+			script.Reference = reference
+		}
+
+		if scriptType, ok := v["type"].(string); ok {
+			script.Type = ScriptType(scriptType)
+		}
+
+		if extras, ok := v["extras"].([]interface{}); ok {
+			for _, extra := range extras {
+				if extra, ok := extra.(string); ok {
+					script.Extras = append(script.Extras, extra)
+				}
+			}
+		}
+
+		if script.Reference == "" {
+			return Script{}, fmt.Errorf("script %q has neither a callable nor a reference", name)
+		}
+
+		return script, nil
+	}
+
+	return Script{}, fmt.Errorf("script %q must be a string or a table, found %T", name, value)
+}
+
+// parseDependencies parses a Poetry dependency table, whose values are
+// either a version constraint, a table or, for multiple constraints, an
+// array of tables. The 'python' entry is not a dependency and is skipped.
+func parseDependencies(table map[string]interface{}) ([]Dependency, error) {
+	var names []string
+	for name := range table {
+		if name != "python" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var dependencies []Dependency
+	for _, name := range names {
+		switch v := table[name].(type) {
+		case string:
+			dependencies = append(dependencies, Dependency{Name: name, Constraint: v})
+		case map[string]interface{}:
+			dependencies = append(dependencies, parseDependencyTable(name, v))
+		case []map[string]interface{}:
+			for _, constraint := range v {
+				dependencies = append(dependencies, parseDependencyTable(name, constraint))
+			}
+		case []interface{}:
+			for _, constraint := range v {
+				if constraint, ok := constraint.(map[string]interface{}); ok {
+					dependencies = append(dependencies, parseDependencyTable(name, constraint))
+				}
+			}
+		default:
+			return nil, fmt.Errorf("dependency %q must be a string, a table or an array of tables, found %T", name, v)
+		}
+	}
+
+	return dependencies, nil
+}
+
+func parseDependencyTable(name string, table map[string]interface{}) Dependency {
+	dependency := Dependency{Name: name}
+	if version, ok := table["version"].(string); ok {
+		dependency.Constraint = version
+	}
+
+	if path, ok := table["path"].(string); ok {
+		dependency.Path = path
+	}
+
+	if develop, ok := table["develop"].(bool); ok {
+		dependency.Develop = develop
+	}
+
+	if optional, ok := table["optional"].(bool); ok {
+		dependency.Optional = optional
+	}
+
+	return dependency
+}
+
+var requirementName = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)\s*(.*)$`)
+
+// parseRequirement parses a PEP 508 requirement string such as
+// "flask[async] >=3 ; python_version >= '3.10'" or
+// "some-lib @ file:///path/to/some-lib".
+func parseRequirement(requirement string) Dependency {
+	matches := requirementName.FindStringSubmatch(requirement)
+	if matches == nil {
+		return Dependency{Name: strings.TrimSpace(requirement)}
+	}
+
+	dependency := Dependency{Name: matches[1], Constraint: strings.TrimSpace(matches[2])}
+	if url, found := strings.CutPrefix(dependency.Constraint, "@"); found {
+		url, _, _ = strings.Cut(url, ";")
+		if path, found := strings.CutPrefix(strings.TrimSpace(url), "file://"); found {
+			dependency.Path = path
+		}
+	}
+
+	return dependency
+}
+
+func mergeTables(tables ...map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for _, table := range tables {
+		for key, value := range table {
+			merged[key] = value
+		}
 	}
 
-	panic("should not be able to get here")
+	return merged
 }
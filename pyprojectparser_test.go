@@ -1,6 +1,8 @@
 package poetryrun_test
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -33,60 +35,275 @@ my-script = "my_module:main"
 		parser = poetryrun.NewPyProjectConfigParser()
 	})
 
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
 	context("parsing", func() {
-		it("returns the key of the only provided script", func() {
-			result, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+		it("returns the provided scripts", func() {
+			pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result).To(Equal("my-script"))
-
+			Expect(pyProject.Path).To(Equal(filepath.Join(workingDir, "pyproject.toml")))
+			Expect(pyProject.Exists).To(BeTrue())
+			Expect(pyProject.Scripts).To(Equal(map[string]poetryrun.Script{
+				"my-script": {Name: "my-script", Type: poetryrun.ConsoleScript, Reference: "my_module:main"},
+			}))
 		})
 
 		context("when there is no pyproject.toml file", func() {
 			it.Before(func() {
 				Expect(os.Remove(filepath.Join(workingDir, "pyproject.toml"))).To(Succeed())
 			})
-			it("returns an empty string without error", func() {
-				script, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+
+			it("returns a project that does not exist without error", func() {
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(pyProject).To(Equal(poetryrun.PyProject{
+					Path: filepath.Join(workingDir, "pyproject.toml"),
+				}))
+			})
+		})
+
+		context("when computing the content hash", func() {
+			it("matches the content-hash that Poetry writes for a [tool.poetry] project", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.poetry]
+name = "default_app"
+version = "0.1.0"
+description = ""
+authors = []
+
+[tool.poetry.dependencies]
+python = "^3.10"
+Flask = "^3"
+
+[tool.poetry.scripts]
+"my script" = "default_app.server:run"
+`), 0644)).To(Succeed())
+
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
 				Expect(err).NotTo(HaveOccurred())
+				Expect(pyProject.ContentHash).To(Equal("ef2a1d3e578560e03a32f9490bbd9fb8e123e7e3f755d69a1ffa06d612590293"))
+			})
+
+			it("matches the content-hash that Poetry writes for a [project] project", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[project]
+name = "some-app"
+requires-python = ">=3.10"
+dependencies = ["flask (>=3,<4)", "naïve-lib 🚀", "tab\tquote\"back\\slash"]
+
+[project.optional-dependencies]
+web = ["gunicorn"]
+
+[tool.poetry]
+package-mode = false
+
+[tool.poetry.group.dev.dependencies]
+pytest = { version = "^8", allow-prereleases = true, weight = 1.5, big = 1e16, count = 3 }
 
-				Expect(script).To(BeEmpty())
+[[tool.poetry.source]]
+name = "internal"
+url = "https://pypi.example.com/simple"
+priority = "supplemental"
+
+[dependency-groups]
+lint = ["ruff"]
+`), 0644)).To(Succeed())
+
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pyProject.ContentHash).To(Equal("4f38f3bc7c18c1b443af3c7c8cfa4efb8ae2ebbab3d17a16ede03877a6e6fae4"))
 			})
 		})
 
-		context("when there are no poetry scripts in the pyproject.toml file", func() {
+		context("when the pyproject.toml sets package-mode", func() {
+			it("reports non-package mode when it is false", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte("[tool.poetry]\npackage-mode = false\n"), 0644)).To(Succeed())
+
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pyProject.NonPackageMode).To(BeTrue())
+			})
+
+			it("does not report non-package mode when it is true", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte("[tool.poetry]\npackage-mode = true\n"), 0644)).To(Succeed())
+
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pyProject.NonPackageMode).To(BeFalse())
+			})
+		})
+
+		context("when the pyproject.toml uses the [tool.poetry] layout", func() {
 			it.Before(func() {
-				Expect(os.Remove(filepath.Join(workingDir, "pyproject.toml"))).To(Succeed())
 				contents := `
-[some.other.valid.toml]
-a-key = "a value"`
+[tool.poetry]
+name = "some-app"
+version = "1.2.3"
+packages = [{ include = "some_app", from = "src" }]
+
+[tool.poetry.scripts]
+console = "some_app.cli:main"
+table = { callable = "some_app.server:app.run", extras = ["web"] }
+file = { reference = "bin/run.py", type = "file" }
+legacy = "some_app.legacy:main [worker, web]"
+
+[tool.poetry.dependencies]
+python = "^3.10"
+flask = "^3"
+some-lib = { path = "../some-lib", develop = true }
+numpy = [
+  { version = "<2", python = "<3.12" },
+  { version = ">=2", python = ">=3.12" },
+]
+
+[tool.poetry.dev-dependencies]
+pytest = "^8"
+
+[tool.poetry.group.lint.dependencies]
+ruff = "*"
+
+[tool.paketo.poetry-run]
+target = "gunicorn some_app:app"
+poetry-options = "--no-plugins --quiet"
+worker-module = "some_app.tasks"
+
+[tool.paketo.poetry-run.processes]
+release = "alembic upgrade head"
+
+[tool.paketo.poetry-run.profiles]
+dev = "python manage.py runserver"
+
+[tool.paketo.poetry-run.health]
+http-path = "/healthz"
+
+[tool.paketo.poetry-run.process-types]
+"my script" = "worker"
+
+[tool.paketo.poetry-run.process-settings.web]
+working-directory = "src"
+env = { DJANGO_SETTINGS_MODULE = "some_app.settings.web" }
+
+[tool.black]
+line-length = 100
+`
 				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(contents), 0644)).To(Succeed())
 			})
 
-			it("returns an empty string without error", func() {
-				script, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+			it("returns the full project model", func() {
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(script).To(BeEmpty())
+				Expect(pyProject.Name).To(Equal("some-app"))
+				Expect(pyProject.Version).To(Equal("1.2.3"))
+				Expect(pyProject.PythonConstraint).To(Equal("^3.10"))
+
+				Expect(pyProject.Scripts).To(Equal(map[string]poetryrun.Script{
+					"console": {Name: "console", Type: poetryrun.ConsoleScript, Reference: "some_app.cli:main"},
+					"table":   {Name: "table", Type: poetryrun.ConsoleScript, Reference: "some_app.server:app.run", Extras: []string{"web"}},
+					"file":    {Name: "file", Type: poetryrun.FileScript, Reference: "bin/run.py"},
+					"legacy":  {Name: "legacy", Type: poetryrun.ConsoleScript, Reference: "some_app.legacy:main", Extras: []string{"worker", "web"}},
+				}))
+
+				Expect(pyProject.DependencyGroups).To(Equal(map[string][]poetryrun.Dependency{
+					"main": {
+						{Name: "flask", Constraint: "^3"},
+						{Name: "numpy", Constraint: "<2"},
+						{Name: "numpy", Constraint: ">=2"},
+						{Name: "some-lib", Path: "../some-lib", Develop: true},
+					},
+					"dev": {
+						{Name: "pytest", Constraint: "^8"},
+					},
+					"lint": {
+						{Name: "ruff", Constraint: "*"},
+					},
+				}))
+
+				Expect(pyProject.Packages).To(Equal([]poetryrun.Package{
+					{Include: "some_app", From: "src"},
+				}))
+
+				Expect(pyProject.PoetryRun).To(Equal(poetryrun.PoetryRunConfig{
+					Target:        "gunicorn some_app:app",
+					PoetryOptions: "--no-plugins --quiet",
+					WorkerModule:  "some_app.tasks",
+					Processes: map[string]string{
+						"release": "alembic upgrade head",
+					},
+					Profiles: map[string]string{
+						"dev": "python manage.py runserver",
+					},
+					Health: poetryrun.HealthConfig{
+						HTTPPath: "/healthz",
+					},
+					ProcessTypes: map[string]string{
+						"my script": "worker",
+					},
+					ProcessSettings: map[string]poetryrun.ProcessSettings{
+						"web": {
+							WorkingDirectory: "src",
+							Env:              map[string]string{"DJANGO_SETTINGS_MODULE": "some_app.settings.web"},
+						},
+					},
+				}))
+
+				Expect(pyProject.Tool).To(HaveKeyWithValue("black", map[string]interface{}{"line-length": int64(100)}))
+				Expect(pyProject.Tool).To(HaveKey("poetry"))
 			})
 		})
 
-		context("when there are multiple poetry scripts in the pyproject.toml file", func() {
+		context("when the pyproject.toml uses the PEP 621 [project] layout", func() {
 			it.Before(func() {
-				Expect(os.Remove(filepath.Join(workingDir, "pyproject.toml"))).To(Succeed())
 				contents := `
-[tool.poetry.scripts]
-my-script = "my_module:main"
-my-other-script = "my_other_module:main"
+[project]
+name = "some-app"
+version = "2.0.0"
+requires-python = ">=3.11"
+dependencies = [
+  "flask[async] >=3 ; python_version >= '3.11'",
+  "some-lib @ file:///workspace/libs/some-lib",
+]
+
+[project.optional-dependencies]
+worker = ["celery>=5"]
+
+[project.scripts]
+serve = "some_app.server:main"
+
+[dependency-groups]
+test = ["pytest>=8", { include-group = "lint" }]
 `
 				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(contents), 0644)).To(Succeed())
 			})
 
-			it("returns an empty string without error", func() {
-				script, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+			it("returns the full project model", func() {
+				pyProject, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(script).To(BeEmpty())
+				Expect(pyProject.Name).To(Equal("some-app"))
+				Expect(pyProject.Version).To(Equal("2.0.0"))
+				Expect(pyProject.PythonConstraint).To(Equal(">=3.11"))
+
+				Expect(pyProject.Scripts).To(Equal(map[string]poetryrun.Script{
+					"serve": {Name: "serve", Type: poetryrun.ConsoleScript, Reference: "some_app.server:main"},
+				}))
+
+				Expect(pyProject.DependencyGroups).To(Equal(map[string][]poetryrun.Dependency{
+					"main": {
+						{Name: "flask", Constraint: "[async] >=3 ; python_version >= '3.11'"},
+						{Name: "some-lib", Constraint: "@ file:///workspace/libs/some-lib", Path: "/workspace/libs/some-lib"},
+					},
+					"worker": {
+						{Name: "celery", Constraint: ">=5", Optional: true},
+					},
+					"test": {
+						{Name: "pytest", Constraint: ">=8"},
+					},
+				}))
 			})
 		})
 
@@ -106,9 +323,29 @@ my-other-script = "my_other_module:main"
 				})
 			})
 
+			context("when the pyproject.toml is not valid TOML", func() {
+				it.Before(func() {
+					contents := `
+[tool.poetry.scripts]
+my-script = "my_module:main
+`
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(contents), 0644)).To(Succeed())
+				})
+
+				it("returns an invalid TOML detection error with the position of the syntax error", func() {
+					_, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+
+					var detectionErr poetryrun.DetectionError
+					Expect(errors.As(err, &detectionErr)).To(BeTrue())
+					Expect(detectionErr.Reason).To(Equal(poetryrun.InvalidTOML))
+					Expect(detectionErr.Line).To(Equal(3))
+					Expect(detectionErr.Column).To(Equal(28))
+					Expect(err).To(MatchError(fmt.Sprintf("failed to parse %s at line 3, column 28: strings cannot contain newlines", filepath.Join(workingDir, "pyproject.toml"))))
+				})
+			})
+
 			context("when the pyproject.toml does not contain the expected TOML structure", func() {
 				it.Before(func() {
-					Expect(os.Remove(filepath.Join(workingDir, "pyproject.toml"))).To(Succeed())
 					contents := `
 [tool.poetry.scripts]
 a-key = [ "a value", "another value"]`
@@ -116,12 +353,41 @@ a-key = [ "a value", "another value"]`
 					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(contents), 0644)).To(Succeed())
 				})
 
+				it("returns an error", func() {
+					_, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+					Expect(err).To(MatchError(ContainSubstring(`script "a-key" must be a string or a table`)))
+				})
+			})
+
+			context("when a script table has neither a callable nor a reference", func() {
+				it.Before(func() {
+					contents := `
+[tool.poetry.scripts]
+a-key = { extras = ["web"] }`
+
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(contents), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
+					Expect(err).To(MatchError(ContainSubstring(`script "a-key" has neither a callable nor a reference`)))
+				})
+			})
+
+			context("when the pyproject.toml has a [project] section of the wrong type", func() {
+				it.Before(func() {
+					contents := `
+[project]
+name = 1`
+
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(contents), 0644)).To(Succeed())
+				})
+
 				it("returns an error", func() {
 					_, err := parser.Parse(filepath.Join(workingDir, "pyproject.toml"))
 					Expect(err).To(MatchError(ContainSubstring("incompatible types: TOML")))
 				})
 			})
 		})
-
 	})
 }
@@ -0,0 +1,330 @@
+package poetryrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/libreload-packit"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// LiveReloadStrategy is how the reloadable process restarts the app when its
+// files change.
+type LiveReloadStrategy string
+
+const (
+	// WatchexecStrategy wraps the process in watchexec, which is provided by
+	// the Watchexec buildpack.
+	WatchexecStrategy LiveReloadStrategy = "watchexec"
+
+	// FrameworkNativeStrategy enables the reloader built into the dev server
+	// of the framework, e.g. 'uvicorn --reload'.
+	FrameworkNativeStrategy LiveReloadStrategy = "framework-native"
+
+	// NoReloadStrategy disables live reload.
+	NoReloadStrategy LiveReloadStrategy = "none"
+)
+
+// liveReload returns the strategy set by BP_LIVE_RELOAD_STRATEGY, which
+// defaults to watchexec, and whether live reload is enabled.
+func (r Resolver) liveReload(reloader Reloader) (LiveReloadStrategy, bool, error) {
+	value, _ := r.lookupEnv("BP_LIVE_RELOAD_STRATEGY")
+	strategy := LiveReloadStrategy(value)
+	switch strategy {
+	case "":
+		strategy = WatchexecStrategy
+	case WatchexecStrategy, FrameworkNativeStrategy:
+	case NoReloadStrategy:
+		return strategy, false, nil
+	default:
+		return "", false, fmt.Errorf("invalid BP_LIVE_RELOAD_STRATEGY value %s: must be one of watchexec, framework-native or none", strategy)
+	}
+
+	enabled, err := reloader.ShouldEnableLiveReload()
+	if err != nil {
+		return "", false, err
+	}
+
+	return strategy, enabled, nil
+}
+
+// frameworkReloadCommand returns the fixed command and the default args of
+// the given target with the flags that enable the reloader built into its dev
+// server, watching the given paths where the server supports it. The flags
+// are part of the fixed command, together with the subcommand they follow, so
+// that replacing the args when the image is run keeps the reloader enabled.
+// Django's runserver reloads by default, so only '--noreload' is removed from
+// it.
+func frameworkReloadCommand(target Target, watchPaths []string) (command, args []string, err error) {
+	argv := target.Argv
+
+	switch {
+	case filepath.Base(argv[0]) == "uvicorn":
+		command = append([]string{argv[0], "--reload"}, reloadDirs(watchPaths)...)
+		args = argv[1:]
+
+	case filepath.Base(argv[0]) == "gunicorn":
+		command = []string{argv[0], "--reload"}
+		args = argv[1:]
+
+	case filepath.Base(argv[0]) == "flask" && indexOf(argv, "run") > 0:
+		run := indexOf(argv, "run")
+		command = append(append([]string{}, argv[:run+1]...), "--reload")
+		args = argv[run+1:]
+
+	case isDjangoRunserver(argv):
+		runserver := indexOf(argv, "runserver")
+		for _, arg := range argv[:runserver+1] {
+			if arg != "--noreload" {
+				command = append(command, arg)
+			}
+		}
+		for _, arg := range argv[runserver+1:] {
+			if arg != "--noreload" {
+				args = append(args, arg)
+			}
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("BP_LIVE_RELOAD_STRATEGY=framework-native does not support %q: use uvicorn, gunicorn, 'flask run' or Django's runserver, or set BP_LIVE_RELOAD_STRATEGY=watchexec", argv[0])
+	}
+
+	if len(args) == 0 {
+		args = nil
+	}
+
+	return command, args, nil
+}
+
+func reloadDirs(watchPaths []string) []string {
+	var flags []string
+	for _, path := range watchPaths {
+		flags = append(flags, "--reload-dir", path)
+	}
+
+	return flags
+}
+
+func isDjangoRunserver(argv []string) bool {
+	if filepath.Base(argv[0]) == "django-admin" {
+		return indexOf(argv, "runserver") > 0
+	}
+
+	return isPythonExecutable(argv[0]) && len(argv) >= 3 && filepath.Base(argv[1]) == "manage.py" && argv[2] == "runserver"
+}
+
+func indexOf(argv []string, arg string) int {
+	for i, a := range argv {
+		if a == arg {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ReloadMode is what watchexec does when the app's files change while it is
+// running.
+type ReloadMode string
+
+const (
+	// RestartMode stops the process with the stop signal and starts it again.
+	RestartMode ReloadMode = "restart"
+
+	// SignalMode sends a signal to the process, which is expected to reload
+	// itself, e.g. on SIGHUP.
+	SignalMode ReloadMode = "signal"
+)
+
+// ReloadSpec extends the reloadable process spec of libreload with the
+// graceful restart settings of watchexec.
+type ReloadSpec struct {
+	libreload.ReloadableProcessSpec
+
+	// StopSignal is the signal that stops the process before a restart,
+	// e.g. SIGTERM.
+	StopSignal string
+
+	// StopTimeout is how long to wait for the process to stop before it is
+	// killed, e.g. 10s.
+	StopTimeout string
+
+	// Debounce is how long to wait for further changes before reloading,
+	// e.g. 500ms.
+	Debounce string
+
+	// Mode is whether the process is restarted or signalled on change.
+	Mode ReloadMode
+
+	// Signal is the signal sent to the process in SignalMode.
+	Signal string
+
+	// ClearScreen clears the screen before each reload.
+	ClearScreen bool
+}
+
+var signalPattern = regexp.MustCompile(`^SIG[A-Z0-9]+$`)
+
+// reloadSpec returns the spec of the reloadable process, watching the given
+// paths, with the graceful restart settings from BP_LIVE_RELOAD_STOP_SIGNAL,
+// BP_LIVE_RELOAD_STOP_TIMEOUT, BP_LIVE_RELOAD_DEBOUNCE,
+// BP_LIVE_RELOAD_ON_CHANGE, BP_LIVE_RELOAD_SIGNAL and
+// BP_LIVE_RELOAD_CLEAR_SCREEN.
+func (r Resolver) reloadSpec(watchPaths []string) (ReloadSpec, error) {
+	spec := ReloadSpec{
+		ReloadableProcessSpec: libreload.ReloadableProcessSpec{WatchPaths: watchPaths},
+		Mode:                  RestartMode,
+	}
+
+	var err error
+	spec.StopSignal, err = r.lookupSignalEnv("BP_LIVE_RELOAD_STOP_SIGNAL")
+	if err != nil {
+		return ReloadSpec{}, err
+	}
+
+	spec.Signal, err = r.lookupSignalEnv("BP_LIVE_RELOAD_SIGNAL")
+	if err != nil {
+		return ReloadSpec{}, err
+	}
+
+	spec.StopTimeout, err = r.lookupDurationEnv("BP_LIVE_RELOAD_STOP_TIMEOUT")
+	if err != nil {
+		return ReloadSpec{}, err
+	}
+
+	spec.Debounce, err = r.lookupDurationEnv("BP_LIVE_RELOAD_DEBOUNCE")
+	if err != nil {
+		return ReloadSpec{}, err
+	}
+
+	if mode, ok := r.lookupEnv("BP_LIVE_RELOAD_ON_CHANGE"); ok {
+		switch ReloadMode(mode) {
+		case RestartMode, SignalMode:
+			spec.Mode = ReloadMode(mode)
+		default:
+			return ReloadSpec{}, fmt.Errorf("invalid BP_LIVE_RELOAD_ON_CHANGE value %s: must be restart or signal", mode)
+		}
+	}
+
+	if spec.Mode == SignalMode && spec.Signal == "" {
+		spec.Signal = "SIGHUP"
+	}
+
+	spec.ClearScreen, err = r.lookupBoolEnv("BP_LIVE_RELOAD_CLEAR_SCREEN")
+	if err != nil {
+		return ReloadSpec{}, err
+	}
+
+	return spec, nil
+}
+
+// isGraceful reports whether any of the graceful restart settings differs
+// from the watchexec defaults.
+func (s ReloadSpec) isGraceful() bool {
+	return s.StopSignal != "" || s.StopTimeout != "" || s.Debounce != "" || s.Mode != RestartMode || s.ClearScreen
+}
+
+// watchexecArgs applies the graceful restart settings to the arguments of a
+// watchexec process.
+func (s ReloadSpec) watchexecArgs(args []string) []string {
+	var flags []string
+	if s.StopSignal != "" {
+		flags = append(flags, "--stop-signal", s.StopSignal)
+	}
+
+	if s.StopTimeout != "" {
+		flags = append(flags, "--stop-timeout", s.StopTimeout)
+	}
+
+	if s.Debounce != "" {
+		flags = append(flags, "--debounce", s.Debounce)
+	}
+
+	if s.Mode == SignalMode {
+		flags = append(flags, "--on-busy-update", "signal", "--signal", s.Signal)
+	}
+
+	if s.ClearScreen {
+		flags = append(flags, "--clear")
+	}
+
+	for _, arg := range args {
+		if s.Mode == SignalMode && arg == "--restart" {
+			continue
+		}
+		flags = append(flags, arg)
+	}
+
+	return flags
+}
+
+func (r Resolver) lookupSignalEnv(name string) (string, error) {
+	value, ok := r.lookupEnv(name)
+	if !ok || value == "" {
+		return "", nil
+	}
+
+	signal := strings.ToUpper(value)
+	if !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+
+	if !signalPattern.MatchString(signal) {
+		return "", fmt.Errorf("invalid %s value %s: must be a signal name, e.g. SIGTERM", name, value)
+	}
+
+	return signal, nil
+}
+
+func (r Resolver) lookupDurationEnv(name string) (string, error) {
+	value, ok := r.lookupEnv(name)
+	if !ok || value == "" {
+		return "", nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return "", fmt.Errorf("invalid %s value %s: must be a duration, e.g. 500ms or 10s", name, value)
+	}
+
+	return value, nil
+}
+
+// syncPaths returns the files of the project in the given directory whose
+// changes trigger a dependency sync.
+func syncPaths(projectDir string) []string {
+	return []string{
+		filepath.Join(projectDir, "pyproject.toml"),
+		filepath.Join(projectDir, "poetry.lock"),
+	}
+}
+
+// syncDependenciesProcess wraps the process in the sync-dependencies
+// supervisor at the given path. The supervisor runs 'poetry install --sync',
+// with the given global options of Poetry, and restarts the process whenever
+// one of the given files changes, and keeps the process running when the
+// install fails. The process is stopped with the stop signal and timeout of
+// the given spec, and the default args are carried over, so they are still
+// passed to the process.
+func syncDependenciesProcess(binary string, process packit.DirectProcess, paths, options []string, spec ReloadSpec) packit.DirectProcess {
+	command := []string{binary}
+	for _, path := range paths {
+		command = append(command, "--watch", path)
+	}
+	if len(options) > 0 {
+		command = append(command, "--install", strings.Join(poetryInstallCommand(options), " "))
+	}
+	if spec.StopSignal != "" {
+		command = append(command, "--stop-signal", spec.StopSignal)
+	}
+	if spec.StopTimeout != "" {
+		command = append(command, "--stop-timeout", spec.StopTimeout)
+	}
+	command = append(command, "--")
+
+	process.Command = append(command, process.Command...)
+	return process
+}
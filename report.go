@@ -0,0 +1,165 @@
+package poetryrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// Report is a machine-readable account of the processes Build assigned and
+// of where each decision came from. Build writes it to the poetry-run layer
+// as report.toml and report.json.
+type Report struct {
+	Target    ReportTarget    `toml:"target" json:"target"`
+	Processes []ReportProcess `toml:"processes" json:"processes"`
+	Profiles  []ReportProfile `toml:"profiles" json:"profiles"`
+	Reload    ReportReload    `toml:"reload" json:"reload"`
+	Runtime   ReportRuntime   `toml:"runtime" json:"runtime"`
+	Warnings  []string        `toml:"warnings" json:"warnings"`
+}
+
+// ReportTarget is the resolved poetry run target.
+type ReportTarget struct {
+	Kind        TargetKind   `toml:"kind" json:"kind"`
+	Command     []string     `toml:"command" json:"command"`
+	Source      TargetSource `toml:"source" json:"source"`
+	Explanation string       `toml:"explanation" json:"explanation"`
+}
+
+// ReportProcess is a launch process assigned by Build.
+type ReportProcess struct {
+	Type    string       `toml:"type" json:"type"`
+	Command []string     `toml:"command" json:"command"`
+	Args    []string     `toml:"args" json:"args"`
+	Default bool         `toml:"default" json:"default"`
+	Source  TargetSource `toml:"source" json:"source"`
+
+	WorkingDirectory string            `toml:"working-directory,omitempty" json:"working-directory,omitempty"`
+	Env              map[string]string `toml:"env,omitempty" json:"env,omitempty"`
+}
+
+// ReportProfile is a run profile baked into the image.
+type ReportProfile struct {
+	Name    string       `toml:"name" json:"name"`
+	Command []string     `toml:"command" json:"command"`
+	Source  TargetSource `toml:"source" json:"source"`
+}
+
+// ReportRuntime describes the Python runtime the app was built with. It is
+// also recorded in the image labels, so that images that need a rebuild
+// after a rebase can be found.
+type ReportRuntime struct {
+	// CPythonVersion is the version of the CPython the venv was created from,
+	// read from its pyvenv.cfg, e.g. "3.11.9".
+	CPythonVersion string `toml:"cpython-version,omitempty" json:"cpython-version,omitempty"`
+
+	// Venv is the path of the venv of the project.
+	Venv string `toml:"venv,omitempty" json:"venv,omitempty"`
+
+	// VenvPythonVersion is the Python version the venv was created with,
+	// e.g. "3.11.9".
+	VenvPythonVersion string `toml:"venv-python-version,omitempty" json:"venv-python-version,omitempty"`
+}
+
+// ReportReload describes the live reload settings.
+type ReportReload struct {
+	Enabled    bool               `toml:"enabled" json:"enabled"`
+	Strategy   LiveReloadStrategy `toml:"strategy" json:"strategy"`
+	WatchPaths []string           `toml:"watch-paths" json:"watch-paths"`
+	SyncPaths  []string           `toml:"sync-paths,omitempty" json:"sync-paths,omitempty"`
+}
+
+// Summary returns the report as 'key = value' lines in a stable order, for
+// the build log.
+func (r Report) Summary() []string {
+	lines := []string{
+		fmt.Sprintf("target.command = %s", strings.Join(r.Target.Command, " ")),
+		fmt.Sprintf("target.kind = %s", r.Target.Kind),
+		fmt.Sprintf("target.source = %s", r.Target.Source),
+	}
+
+	for _, process := range r.Processes {
+		command := strings.Join(append(append([]string{}, process.Command...), process.Args...), " ")
+		lines = append(lines, fmt.Sprintf("process.%s = %s", process.Type, command))
+		lines = append(lines, fmt.Sprintf("process.%s.default = %t", process.Type, process.Default))
+		lines = append(lines, fmt.Sprintf("process.%s.source = %s", process.Type, process.Source))
+		if process.WorkingDirectory != "" {
+			lines = append(lines, fmt.Sprintf("process.%s.working-directory = %s", process.Type, process.WorkingDirectory))
+		}
+		for _, name := range envNames(process.Env) {
+			lines = append(lines, fmt.Sprintf("process.%s.env.%s = %s", process.Type, name, redact(process.Env[name])))
+		}
+	}
+
+	for _, profile := range r.Profiles {
+		lines = append(lines, fmt.Sprintf("profile.%s = %s", profile.Name, strings.Join(profile.Command, " ")))
+		lines = append(lines, fmt.Sprintf("profile.%s.source = %s", profile.Name, profile.Source))
+	}
+
+	lines = append(lines, fmt.Sprintf("reload.enabled = %t", r.Reload.Enabled))
+	if r.Reload.Enabled {
+		lines = append(lines, fmt.Sprintf("reload.strategy = %s", r.Reload.Strategy))
+		lines = append(lines, fmt.Sprintf("reload.watch-paths = %s", strings.Join(r.Reload.WatchPaths, ":")))
+		if len(r.Reload.SyncPaths) > 0 {
+			lines = append(lines, fmt.Sprintf("reload.sync-paths = %s", strings.Join(r.Reload.SyncPaths, ":")))
+		}
+	}
+
+	if r.Runtime.CPythonVersion != "" {
+		lines = append(lines, fmt.Sprintf("runtime.cpython-version = %s", r.Runtime.CPythonVersion))
+	}
+
+	if r.Runtime.Venv != "" {
+		lines = append(lines, fmt.Sprintf("runtime.venv = %s", r.Runtime.Venv))
+		lines = append(lines, fmt.Sprintf("runtime.venv-python-version = %s", r.Runtime.VenvPythonVersion))
+	}
+
+	for _, warning := range r.Warnings {
+		lines = append(lines, fmt.Sprintf("warning = %s", warning))
+	}
+
+	return lines
+}
+
+// writeReport writes the report to report.toml and report.json in the given
+// layer.
+func writeReport(layer packit.Layer, report Report) error {
+	buffer := bytes.NewBuffer(nil)
+	err := toml.NewEncoder(buffer).Encode(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode build report: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(layer.Path, "report.toml"), buffer.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build report: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(layer.Path, "report.json"), append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+
+	return nil
+}
+
+// redact hides the given environment variable value in the build log, as it
+// may be a secret, while still showing whether it is empty.
+func redact(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	return "********"
+}
@@ -0,0 +1,61 @@
+package poetryrun_test
+
+import (
+	"testing"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testReport(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("Summary", func() {
+		it("returns the report as key = value lines in a stable order", func() {
+			report := poetryrun.Report{
+				Target: poetryrun.ReportTarget{
+					Kind:    poetryrun.CommandTarget,
+					Command: []string{"poetry", "run", "gunicorn", "app:app"},
+					Source:  poetryrun.EnvironmentSource,
+				},
+				Processes: []poetryrun.ReportProcess{
+					{
+						Type:    "web",
+						Command: []string{"poetry", "run", "gunicorn"},
+						Args:    []string{"app:app"},
+						Default: true,
+						Source:  poetryrun.EnvironmentSource,
+
+						WorkingDirectory: "/workspace/src",
+						Env:              map[string]string{"SOME_VAR": "some-value", "OTHER_VAR": "other-value"},
+					},
+				},
+				Runtime: poetryrun.ReportRuntime{
+					CPythonVersion:    "3.11.9",
+					Venv:              "/layers/poetry-venv/some-app-py3.11",
+					VenvPythonVersion: "3.11.9",
+				},
+				Warnings: []string{"some warning"},
+			}
+
+			Expect(report.Summary()).To(Equal([]string{
+				"target.command = poetry run gunicorn app:app",
+				"target.kind = command",
+				"target.source = environment",
+				"process.web = poetry run gunicorn app:app",
+				"process.web.default = true",
+				"process.web.source = environment",
+				"process.web.working-directory = /workspace/src",
+				"process.web.env.OTHER_VAR = ********",
+				"process.web.env.SOME_VAR = ********",
+				"reload.enabled = false",
+				"runtime.cpython-version = 3.11.9",
+				"runtime.venv = /layers/poetry-venv/some-app-py3.11",
+				"runtime.venv-python-version = 3.11.9",
+				"warning = some warning",
+			}))
+		})
+	})
+}
@@ -0,0 +1,396 @@
+package poetryrun
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TargetKind describes what a poetry run target refers to.
+type TargetKind string
+
+const (
+	// ScriptTarget is a script defined in pyproject.toml.
+	ScriptTarget TargetKind = "script"
+
+	// ModuleTarget is a Python module run with 'python -m'.
+	ModuleTarget TargetKind = "module"
+
+	// CommandTarget is any other executable on the PATH of the venv.
+	CommandTarget TargetKind = "command"
+
+	// CallableTarget is the callable of a script run with 'python -c', for
+	// projects whose scripts Poetry does not install.
+	CallableTarget TargetKind = "callable"
+)
+
+// TargetSource names the strategy that resolved a target.
+type TargetSource string
+
+const (
+	// EnvironmentSource is the BP_POETRY_RUN_TARGET environment variable.
+	EnvironmentSource TargetSource = "environment"
+
+	// ConfigurationSource is the target key of [tool.paketo.poetry-run] in
+	// pyproject.toml.
+	ConfigurationSource TargetSource = "pyproject-config"
+
+	// ScriptSource is the only script defined in pyproject.toml.
+	ScriptSource TargetSource = "single-script"
+
+	// PreferredScriptSource is the script with the first preferred name, when
+	// pyproject.toml defines several.
+	PreferredScriptSource TargetSource = "preferred-script"
+
+	// ModuleSource is a package of the project with a __main__.py module.
+	ModuleSource TargetSource = "module"
+
+	// FrameworkSource is the conventional entrypoint of a web framework the
+	// project depends on.
+	FrameworkSource TargetSource = "framework"
+
+	// WorkerSource is a task queue worker inferred from the dependencies of
+	// the project.
+	WorkerSource TargetSource = "worker"
+)
+
+// Target is the resolved answer to "which poetry command starts this app?".
+type Target struct {
+	Kind TargetKind
+
+	// Argv holds the arguments that follow 'poetry run'.
+	Argv []string
+
+	Source TargetSource
+
+	// Explanation is a human readable account of how the target was found.
+	Explanation string
+}
+
+// Split separates the fixed command of the target (a script key, an
+// executable, 'python -m <module>' or 'python -c <code>') from the arguments
+// that follow it. The latter become the default args of the launch process
+// and can be replaced when the image is run.
+func (t Target) Split() (command, args []string) {
+	n := 1
+	if t.Kind == ModuleTarget || t.Kind == CallableTarget {
+		n = 3
+	}
+
+	if n > len(t.Argv) {
+		n = len(t.Argv)
+	}
+
+	command = t.Argv[:n]
+	if len(t.Argv) > n {
+		args = t.Argv[n:]
+	}
+
+	return command, args
+}
+
+// Resolver resolves the poetry run target of an app. The environment and the
+// app's filesystem are injected so that other buildpacks and tools can reuse
+// the same decision logic outside of the buildpack lifecycle.
+type Resolver struct {
+	environ func() []string
+	appFS   fs.FS
+}
+
+// NewResolver returns a Resolver that reads configuration from the
+// "NAME=value" variables listed by environ (e.g. os.Environ) and inspects the
+// app through appFS, which must be rooted at the app directory (e.g.
+// os.DirFS(workingDir)).
+func NewResolver(environ func() []string, appFS fs.FS) Resolver {
+	return Resolver{
+		environ: environ,
+		appFS:   appFS,
+	}
+}
+
+// lookupEnv returns the value of the given environment variable and whether
+// it is set, like os.LookupEnv.
+func (r Resolver) lookupEnv(name string) (string, bool) {
+	for _, variable := range r.environ() {
+		if key, value, _ := strings.Cut(variable, "="); key == name {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+type resolverStrategy func(Resolver, PyProject) (Target, bool, error)
+
+// Resolve runs the resolution strategies in order and returns the target of
+// the first one that applies: BP_POETRY_RUN_TARGET, the target configured in
+// [tool.paketo.poetry-run], the only script in pyproject.toml, a package with
+// a __main__.py module, and finally a framework entrypoint.
+//
+// When a project defines multiple scripts, resolution stops with a
+// DetectionError rather than guessing, unless the preferred script names
+// are enabled and pick exactly one of them. When no strategy applies, Resolve
+// returns the DetectionError explaining why no script was found.
+func (r Resolver) Resolve(pyProject PyProject) (Target, error) {
+	strategies := []resolverStrategy{
+		resolveFromEnvironment,
+		resolveFromConfiguration,
+		resolveSingleScript,
+		resolveMainModule,
+		resolveFramework,
+	}
+
+	for _, strategy := range strategies {
+		target, ok, err := strategy(r, pyProject)
+		if err != nil {
+			return Target{}, err
+		}
+
+		if ok {
+			return target, nil
+		}
+	}
+
+	_, err := pyProject.SingleScript()
+	return Target{}, err
+}
+
+func resolveFromEnvironment(r Resolver, pyProject PyProject) (Target, bool, error) {
+	runTarget, ok := r.lookupEnv("BP_POETRY_RUN_TARGET")
+	if !ok {
+		return Target{}, false, nil
+	}
+
+	expanded, err := r.expand("BP_POETRY_RUN_TARGET", runTarget)
+	if err != nil {
+		return Target{}, false, err
+	}
+
+	argv := strings.Fields(expanded)
+	if len(argv) == 0 {
+		return Target{}, false, fmt.Errorf("BP_POETRY_RUN_TARGET must not be empty")
+	}
+
+	return Target{
+		Kind:        targetKind(argv, pyProject),
+		Argv:        argv,
+		Source:      EnvironmentSource,
+		Explanation: expandedExplanation(fmt.Sprintf("Found BP_POETRY_RUN_TARGET=%s", runTarget), runTarget, expanded),
+	}, true, nil
+}
+
+func resolveFromConfiguration(r Resolver, pyProject PyProject) (Target, bool, error) {
+	expanded, err := r.expand("[tool.paketo.poetry-run] target", pyProject.PoetryRun.Target)
+	if err != nil {
+		return Target{}, false, err
+	}
+
+	argv := strings.Fields(expanded)
+	if len(argv) == 0 {
+		return Target{}, false, nil
+	}
+
+	return Target{
+		Kind:        targetKind(argv, pyProject),
+		Argv:        argv,
+		Source:      ConfigurationSource,
+		Explanation: expandedExplanation(fmt.Sprintf("Found [tool.paketo.poetry-run] target=%s", pyProject.PoetryRun.Target), pyProject.PoetryRun.Target, expanded),
+	}, true, nil
+}
+
+func expandedExplanation(explanation, target, expanded string) string {
+	if expanded == target {
+		return explanation
+	}
+
+	return fmt.Sprintf("%s, expanded to %s", explanation, expanded)
+}
+
+func resolveSingleScript(r Resolver, pyProject PyProject) (Target, bool, error) {
+	script, err := pyProject.SingleScript()
+	if err != nil {
+		var detectionErr DetectionError
+		if errors.As(err, &detectionErr) && detectionErr.Reason == MultipleScripts {
+			return r.resolvePreferredScript(pyProject, detectionErr)
+		}
+
+		return Target{}, false, nil
+	}
+
+	return Target{
+		Kind:        ScriptTarget,
+		Argv:        []string{script},
+		Source:      ScriptSource,
+		Explanation: fmt.Sprintf("Found pyproject.toml script=%s", script),
+	}, true, nil
+}
+
+// defaultPreferredScripts are the script names that
+// BP_POETRY_RUN_PREFER_SCRIPTS picks from, in order.
+var defaultPreferredScripts = []string{"web", "serve", "server", "start", "app"}
+
+// resolvePreferredScript picks the script named by the first of the preferred
+// script names that any script has, when the heuristic is enabled with
+// BP_POETRY_RUN_PREFER_SCRIPTS or BP_POETRY_RUN_PREFERRED_SCRIPTS. Names
+// are compared case-insensitively, with '_' and '-' treated alike. When it is
+// not enabled, the given error for multiple scripts is returned; when no
+// script or more than one script matches, an AmbiguousScripts error.
+func (r Resolver) resolvePreferredScript(pyProject PyProject, multipleScripts DetectionError) (Target, bool, error) {
+	preferred, err := r.preferredScripts()
+	if err != nil {
+		return Target{}, false, err
+	}
+
+	if len(preferred) == 0 {
+		return Target{}, false, multipleScripts
+	}
+
+	for _, name := range preferred {
+		var matches []string
+		for _, script := range multipleScripts.Scripts {
+			if scriptNameKey(script) == scriptNameKey(name) {
+				matches = append(matches, script)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return Target{
+				Kind:        ScriptTarget,
+				Argv:        []string{matches[0]},
+				Source:      PreferredScriptSource,
+				Explanation: fmt.Sprintf("Found pyproject.toml script=%s, the first of the preferred scripts %s", matches[0], strings.Join(preferred, ", ")),
+			}, true, nil
+		default:
+			return Target{}, false, DetectionError{Reason: AmbiguousScripts, Path: pyProject.Path, Scripts: multipleScripts.Scripts, Preferred: preferred, Matches: matches}
+		}
+	}
+
+	return Target{}, false, DetectionError{Reason: AmbiguousScripts, Path: pyProject.Path, Scripts: multipleScripts.Scripts, Preferred: preferred}
+}
+
+// preferredScripts returns the ordered list of preferred script names from
+// BP_POETRY_RUN_PREFERRED_SCRIPTS, or the default list when
+// BP_POETRY_RUN_PREFER_SCRIPTS is true. It is empty when neither is set.
+func (r Resolver) preferredScripts() ([]string, error) {
+	if value, ok := r.lookupEnv("BP_POETRY_RUN_PREFERRED_SCRIPTS"); ok {
+		names := strings.FieldsFunc(value, func(c rune) bool { return c == ',' || unicode.IsSpace(c) })
+		if len(names) == 0 {
+			return nil, fmt.Errorf("BP_POETRY_RUN_PREFERRED_SCRIPTS must not be empty")
+		}
+
+		return names, nil
+	}
+
+	value, ok := r.lookupEnv("BP_POETRY_RUN_PREFER_SCRIPTS")
+	if !ok {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse BP_POETRY_RUN_PREFER_SCRIPTS value %s: %w", value, err)
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	return defaultPreferredScripts, nil
+}
+
+func scriptNameKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// projectPackage is a package of the project: its directory in the app and
+// the module it is imported as.
+type projectPackage struct{ dir, module string }
+
+// projectPackages returns the packages listed under [tool.poetry.packages],
+// followed by the package named after the project, at the top level and in a
+// src layout.
+func projectPackages(pyProject PyProject) []projectPackage {
+	var packages []projectPackage
+	for _, pkg := range pyProject.Packages {
+		packages = append(packages, projectPackage{path.Join(pkg.From, pkg.Include), strings.ReplaceAll(pkg.Include, "/", ".")})
+	}
+
+	if pyProject.Name != "" {
+		module := strings.NewReplacer("-", "_", ".", "_").Replace(pyProject.Name)
+		packages = append(packages, projectPackage{module, module}, projectPackage{path.Join("src", module), module})
+	}
+
+	return packages
+}
+
+func resolveMainModule(r Resolver, pyProject PyProject) (Target, bool, error) {
+	for _, c := range projectPackages(pyProject) {
+		if _, err := fs.Stat(r.appFS, path.Join(c.dir, "__main__.py")); err != nil {
+			continue
+		}
+
+		return Target{
+			Kind:        ModuleTarget,
+			Argv:        []string{"python", "-m", c.module},
+			Source:      ModuleSource,
+			Explanation: fmt.Sprintf("Found %s/__main__.py, running python -m %s", c.dir, c.module),
+		}, true, nil
+	}
+
+	return Target{}, false, nil
+}
+
+// frameworkEntrypoints are the conventional entrypoints of the supported web
+// frameworks, in order of precedence.
+var frameworkEntrypoints = []struct {
+	framework  string
+	dependency string
+	file       string
+	argv       []string
+}{
+	{"Django", "django", "manage.py", []string{"python", "manage.py", "runserver", "0.0.0.0:8000"}},
+	{"FastAPI", "uvicorn", "main.py", []string{"uvicorn", "main:app", "--host", "0.0.0.0"}},
+	{"FastAPI", "uvicorn", "app/main.py", []string{"uvicorn", "app.main:app", "--host", "0.0.0.0"}},
+	{"Flask", "flask", "app.py", []string{"flask", "--app", "app", "run", "--host", "0.0.0.0"}},
+	{"Flask", "flask", "wsgi.py", []string{"flask", "--app", "wsgi", "run", "--host", "0.0.0.0"}},
+}
+
+func resolveFramework(r Resolver, pyProject PyProject) (Target, bool, error) {
+	for _, entrypoint := range frameworkEntrypoints {
+		if !pyProject.HasDependency(entrypoint.dependency) {
+			continue
+		}
+
+		if _, err := fs.Stat(r.appFS, entrypoint.file); err != nil {
+			continue
+		}
+
+		return Target{
+			Kind:        CommandTarget,
+			Argv:        append([]string(nil), entrypoint.argv...),
+			Source:      FrameworkSource,
+			Explanation: fmt.Sprintf("Found %s entrypoint %s, running %s", entrypoint.framework, entrypoint.file, strings.Join(entrypoint.argv, " ")),
+		}, true, nil
+	}
+
+	return Target{}, false, nil
+}
+
+func targetKind(argv []string, pyProject PyProject) TargetKind {
+	if _, ok := pyProject.Scripts[argv[0]]; ok {
+		return ScriptTarget
+	}
+
+	if len(argv) >= 3 && isPythonExecutable(argv[0]) && argv[1] == "-m" {
+		return ModuleTarget
+	}
+
+	return CommandTarget
+}
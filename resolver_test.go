@@ -0,0 +1,624 @@
+package poetryrun_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testResolver(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		env       map[string]string
+		appFS     fstest.MapFS
+		pyProject poetryrun.PyProject
+		resolver  poetryrun.Resolver
+	)
+
+	it.Before(func() {
+		env = map[string]string{}
+		appFS = fstest.MapFS{}
+		pyProject = poetryrun.PyProject{
+			Path:   "pyproject.toml",
+			Exists: true,
+			Name:   "some-app",
+			Scripts: map[string]poetryrun.Script{
+				"some-script": {Name: "some-script", Type: poetryrun.ConsoleScript, Reference: "some_app.server:main"},
+			},
+		}
+
+		resolver = poetryrun.NewResolver(func() []string {
+			var environ []string
+			for name, value := range env {
+				environ = append(environ, name+"="+value)
+			}
+			return environ
+		}, appFS)
+	})
+
+	context("when BP_POETRY_RUN_TARGET is set", func() {
+		it.Before(func() {
+			env["BP_POETRY_RUN_TARGET"] = "gunicorn  some_app:app"
+			pyProject.PoetryRun.Target = "some-script"
+		})
+
+		it("resolves a command target from the environment", func() {
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target).To(Equal(poetryrun.Target{
+				Kind:        poetryrun.CommandTarget,
+				Argv:        []string{"gunicorn", "some_app:app"},
+				Source:      poetryrun.EnvironmentSource,
+				Explanation: "Found BP_POETRY_RUN_TARGET=gunicorn  some_app:app",
+			}))
+		})
+
+		context("to a script key", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_TARGET"] = "some-script --some-flag"
+			})
+
+			it("resolves a script target", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(target.Kind).To(Equal(poetryrun.ScriptTarget))
+				Expect(target.Argv).To(Equal([]string{"some-script", "--some-flag"}))
+			})
+		})
+
+		context("to a python module", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_TARGET"] = "python -m some_app"
+			})
+
+			it("resolves a module target", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(target.Kind).To(Equal(poetryrun.ModuleTarget))
+			})
+		})
+	})
+
+	context("when a target is configured in pyproject.toml", func() {
+		it.Before(func() {
+			pyProject.PoetryRun.Target = "python -m some_app.worker"
+			pyProject.Scripts["some-other-script"] = poetryrun.Script{Name: "some-other-script"}
+		})
+
+		it("resolves the configured target", func() {
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target).To(Equal(poetryrun.Target{
+				Kind:        poetryrun.ModuleTarget,
+				Argv:        []string{"python", "-m", "some_app.worker"},
+				Source:      poetryrun.ConfigurationSource,
+				Explanation: "Found [tool.paketo.poetry-run] target=python -m some_app.worker",
+			}))
+		})
+	})
+
+	context("when BP_POETRY_RUN_EXPAND_ENV is true", func() {
+		it.Before(func() {
+			env["BP_POETRY_RUN_EXPAND_ENV"] = "true"
+			env["APP_MODULE"] = "some_app.server"
+			env["EMPTY"] = ""
+		})
+
+		it("expands variables in BP_POETRY_RUN_TARGET", func() {
+			env["BP_POETRY_RUN_TARGET"] = "gunicorn ${APP_MODULE}:app --workers ${WORKERS:-2} --log-level ${EMPTY:-info} --env PRICE=$$5 --env $HOME"
+
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target.Argv).To(Equal([]string{"gunicorn", "some_app.server:app", "--workers", "2", "--log-level", "info", "--env", "PRICE=$5", "--env", "$HOME"}))
+			Expect(target.Explanation).To(Equal("Found BP_POETRY_RUN_TARGET=gunicorn ${APP_MODULE}:app --workers ${WORKERS:-2} --log-level ${EMPTY:-info} --env PRICE=$$5 --env $HOME, expanded to gunicorn some_app.server:app --workers 2 --log-level info --env PRICE=$5 --env $HOME"))
+		})
+
+		it("splits an expanded value into arguments", func() {
+			env["APP_ARGS"] = "--bind 0.0.0.0:8080"
+			env["BP_POETRY_RUN_TARGET"] = "gunicorn ${APP_MODULE}:app ${APP_ARGS}"
+
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target.Argv).To(Equal([]string{"gunicorn", "some_app.server:app", "--bind", "0.0.0.0:8080"}))
+		})
+
+		it("expands variables in the target configured in pyproject.toml", func() {
+			pyProject.PoetryRun.Target = "python -m ${APP_MODULE}"
+
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target).To(Equal(poetryrun.Target{
+				Kind:        poetryrun.ModuleTarget,
+				Argv:        []string{"python", "-m", "some_app.server"},
+				Source:      poetryrun.ConfigurationSource,
+				Explanation: "Found [tool.paketo.poetry-run] target=python -m ${APP_MODULE}, expanded to python -m some_app.server",
+			}))
+		})
+	})
+
+	context("when BP_POETRY_RUN_EXPAND_ENV is not set", func() {
+		it("leaves variables in the target as-is", func() {
+			env["APP_MODULE"] = "some_app.server"
+			env["BP_POETRY_RUN_TARGET"] = "gunicorn ${APP_MODULE}:app"
+
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target.Argv).To(Equal([]string{"gunicorn", "${APP_MODULE}:app"}))
+		})
+	})
+
+	context("when pyproject.toml defines exactly one script", func() {
+		it("resolves the script", func() {
+			target, err := resolver.Resolve(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(target).To(Equal(poetryrun.Target{
+				Kind:        poetryrun.ScriptTarget,
+				Argv:        []string{"some-script"},
+				Source:      poetryrun.ScriptSource,
+				Explanation: "Found pyproject.toml script=some-script",
+			}))
+		})
+	})
+
+	context("when pyproject.toml defines multiple scripts", func() {
+		it.Before(func() {
+			pyProject.Scripts["some-other-script"] = poetryrun.Script{Name: "some-other-script"}
+			appFS["some_app/__main__.py"] = &fstest.MapFile{}
+		})
+
+		it("does not guess and returns a detection error", func() {
+			_, err := resolver.Resolve(pyProject)
+			Expect(err).To(MatchError(poetryrun.DetectionError{
+				Reason:  poetryrun.MultipleScripts,
+				Path:    "pyproject.toml",
+				Scripts: []string{"some-other-script", "some-script"},
+			}))
+		})
+
+		context("when BP_POETRY_RUN_PREFER_SCRIPTS is true", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_PREFER_SCRIPTS"] = "true"
+				pyProject.Scripts["Start"] = poetryrun.Script{Name: "Start"}
+				pyProject.Scripts["server"] = poetryrun.Script{Name: "server"}
+			})
+
+			it("resolves the script with the first preferred name", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(target).To(Equal(poetryrun.Target{
+					Kind:        poetryrun.ScriptTarget,
+					Argv:        []string{"server"},
+					Source:      poetryrun.PreferredScriptSource,
+					Explanation: "Found pyproject.toml script=server, the first of the preferred scripts web, serve, server, start, app",
+				}))
+			})
+
+			context("when no script has a preferred name", func() {
+				it.Before(func() {
+					delete(pyProject.Scripts, "Start")
+					delete(pyProject.Scripts, "server")
+				})
+
+				it("returns a detection error", func() {
+					_, err := resolver.Resolve(pyProject)
+					Expect(err).To(MatchError(poetryrun.DetectionError{
+						Reason:    poetryrun.AmbiguousScripts,
+						Path:      "pyproject.toml",
+						Scripts:   []string{"some-other-script", "some-script"},
+						Preferred: []string{"web", "serve", "server", "start", "app"},
+					}))
+					Expect(err).To(MatchError("none of the scripts defined under [tool.poetry.scripts] in pyproject.toml has a preferred script name (web, serve, server, start, app): some-other-script, some-script"))
+				})
+			})
+
+			context("when several scripts match the same preferred name", func() {
+				it.Before(func() {
+					delete(pyProject.Scripts, "server")
+					pyProject.Scripts["start"] = poetryrun.Script{Name: "start"}
+				})
+
+				it("returns a detection error", func() {
+					_, err := resolver.Resolve(pyProject)
+					Expect(err).To(MatchError("multiple scripts defined under [tool.poetry.scripts] in pyproject.toml match the same preferred script name: Start, start"))
+				})
+			})
+
+			context("when BP_POETRY_RUN_PREFERRED_SCRIPTS is set", func() {
+				it.Before(func() {
+					env["BP_POETRY_RUN_PREFERRED_SCRIPTS"] = "start, serve"
+				})
+
+				it("uses its order instead", func() {
+					target, err := resolver.Resolve(pyProject)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(target.Argv).To(Equal([]string{"Start"}))
+					Expect(target.Source).To(Equal(poetryrun.PreferredScriptSource))
+				})
+			})
+
+			context("when BP_POETRY_RUN_PREFER_SCRIPTS is not a boolean", func() {
+				it.Before(func() {
+					env["BP_POETRY_RUN_PREFER_SCRIPTS"] = "sure"
+				})
+
+				it("returns an error", func() {
+					_, err := resolver.Resolve(pyProject)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_POETRY_RUN_PREFER_SCRIPTS value sure")))
+				})
+			})
+		})
+	})
+
+	context("when pyproject.toml defines no scripts", func() {
+		it.Before(func() {
+			pyProject.Scripts = nil
+		})
+
+		context("and the project package has a __main__.py", func() {
+			it.Before(func() {
+				appFS["src/some_app/__main__.py"] = &fstest.MapFile{}
+			})
+
+			it("resolves the module", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(target).To(Equal(poetryrun.Target{
+					Kind:        poetryrun.ModuleTarget,
+					Argv:        []string{"python", "-m", "some_app"},
+					Source:      poetryrun.ModuleSource,
+					Explanation: "Found src/some_app/__main__.py, running python -m some_app",
+				}))
+			})
+		})
+
+		context("and a configured package has a __main__.py", func() {
+			it.Before(func() {
+				pyProject.Packages = []poetryrun.Package{{Include: "other/pkg", From: "lib"}}
+				appFS["lib/other/pkg/__main__.py"] = &fstest.MapFile{}
+			})
+
+			it("resolves the module", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(target.Argv).To(Equal([]string{"python", "-m", "other.pkg"}))
+			})
+		})
+
+		context("and the project uses a known framework", func() {
+			it.Before(func() {
+				pyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+					"main": {{Name: "Django"}},
+				}
+				appFS["manage.py"] = &fstest.MapFile{}
+			})
+
+			it("resolves the framework entrypoint", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(target).To(Equal(poetryrun.Target{
+					Kind:        poetryrun.CommandTarget,
+					Argv:        []string{"python", "manage.py", "runserver", "0.0.0.0:8000"},
+					Source:      poetryrun.FrameworkSource,
+					Explanation: "Found Django entrypoint manage.py, running python manage.py runserver 0.0.0.0:8000",
+				}))
+			})
+
+			it("returns a target that does not share its arguments with later resolutions", func() {
+				target, err := resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+				target.Argv[3] = "0.0.0.0:9000"
+
+				target, err = resolver.Resolve(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(target.Argv).To(Equal([]string{"python", "manage.py", "runserver", "0.0.0.0:8000"}))
+			})
+		})
+
+		context("and nothing can be inferred", func() {
+			it("returns the detection error", func() {
+				_, err := resolver.Resolve(pyProject)
+				Expect(err).To(MatchError(poetryrun.DetectionError{
+					Reason: poetryrun.NoScripts,
+					Path:   "pyproject.toml",
+				}))
+			})
+		})
+	})
+
+	context("ResolveWorkers", func() {
+		it.Before(func() {
+			pyProject.Name = "some-app"
+		})
+
+		it("returns no workers when the project depends on no task queue", func() {
+			workers, warnings, err := resolver.ResolveWorkers(pyProject)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workers).To(BeEmpty())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		context("when the project depends on celery", func() {
+			it.Before(func() {
+				pyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+					"main": {{Name: "Celery", Constraint: "^5.3"}, {Name: "dramatiq"}},
+				}
+				appFS["some_app/__init__.py"] = &fstest.MapFile{}
+				appFS["some_app/celery.py"] = &fstest.MapFile{}
+				appFS["tasks.py"] = &fstest.MapFile{}
+			})
+
+			it("returns a worker and a beat process for the app package", func() {
+				workers, warnings, err := resolver.ResolveWorkers(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(warnings).To(BeEmpty())
+
+				Expect(workers).To(Equal([]poetryrun.Worker{
+					{
+						ProcessType: "beat",
+						Target: poetryrun.Target{
+							Kind:        poetryrun.CommandTarget,
+							Argv:        []string{"celery", "-A", "some_app", "beat", "--loglevel", "INFO"},
+							Source:      poetryrun.WorkerSource,
+							Explanation: "Found celery in pyproject.toml and Celery app module some_app/celery.py",
+						},
+					},
+					{
+						ProcessType: "worker",
+						Target: poetryrun.Target{
+							Kind:        poetryrun.CommandTarget,
+							Argv:        []string{"celery", "-A", "some_app", "worker", "--loglevel", "INFO"},
+							Source:      poetryrun.WorkerSource,
+							Explanation: "Found celery in pyproject.toml and Celery app module some_app/celery.py",
+						},
+					},
+				}))
+			})
+
+			context("when the module is overridden", func() {
+				it.Before(func() {
+					pyProject.PoetryRun.WorkerModule = "some_app.queue:app"
+					env["BP_POETRY_RUN_WORKER_MODULE"] = "some_app.tasks"
+				})
+
+				it("runs the module from the environment", func() {
+					workers, _, err := resolver.ResolveWorkers(pyProject)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(workers).To(HaveLen(2))
+					Expect(workers[1].Target.Argv).To(Equal([]string{"celery", "-A", "some_app.tasks", "worker", "--loglevel", "INFO"}))
+					Expect(workers[1].Target.Explanation).To(Equal("Found celery in pyproject.toml and BP_POETRY_RUN_WORKER_MODULE=some_app.tasks"))
+				})
+
+				context("only in pyproject.toml", func() {
+					it.Before(func() {
+						delete(env, "BP_POETRY_RUN_WORKER_MODULE")
+					})
+
+					it("runs the configured module", func() {
+						workers, _, err := resolver.ResolveWorkers(pyProject)
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(workers[1].Target.Argv).To(Equal([]string{"celery", "-A", "some_app.queue:app", "worker", "--loglevel", "INFO"}))
+					})
+				})
+			})
+
+			context("when no app module can be located", func() {
+				it.Before(func() {
+					delete(appFS, "some_app/celery.py")
+					delete(appFS, "tasks.py")
+				})
+
+				it("returns a warning", func() {
+					workers, warnings, err := resolver.ResolveWorkers(pyProject)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(workers).To(BeEmpty())
+					Expect(warnings).To(Equal([]string{"found celery in pyproject.toml but no app module to run a worker for: set BP_POETRY_RUN_WORKER_MODULE"}))
+				})
+			})
+		})
+
+		context("when the project depends on dramatiq", func() {
+			it.Before(func() {
+				pyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+					"main": {{Name: "dramatiq", Constraint: "^1.15"}},
+				}
+				appFS["src/some_app/actors.py"] = &fstest.MapFile{}
+			})
+
+			it("returns a worker process for the actors module", func() {
+				workers, _, err := resolver.ResolveWorkers(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(workers).To(HaveLen(1))
+				Expect(workers[0].ProcessType).To(Equal("worker"))
+				Expect(workers[0].Target.Argv).To(Equal([]string{"dramatiq", "some_app.actors"}))
+				Expect(workers[0].Target.Explanation).To(Equal("Found dramatiq in pyproject.toml and Dramatiq app module src/some_app/actors.py"))
+			})
+		})
+
+		context("when the project depends on rq", func() {
+			it.Before(func() {
+				pyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+					"main": {{Name: "rq"}},
+				}
+			})
+
+			it("returns a worker process without an app module", func() {
+				workers, _, err := resolver.ResolveWorkers(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(workers).To(Equal([]poetryrun.Worker{
+					{
+						ProcessType: "worker",
+						Target: poetryrun.Target{
+							Kind:        poetryrun.CommandTarget,
+							Argv:        []string{"rq", "worker"},
+							Source:      poetryrun.WorkerSource,
+							Explanation: "Found rq in pyproject.toml",
+						},
+					},
+				}))
+			})
+
+			context("when the module is overridden", func() {
+				it.Before(func() {
+					env["BP_POETRY_RUN_WORKER_MODULE"] = "some_app.rq_settings"
+				})
+
+				it("passes it as the settings module", func() {
+					workers, _, err := resolver.ResolveWorkers(pyProject)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(workers[0].Target.Argv).To(Equal([]string{"rq", "worker", "-c", "some_app.rq_settings"}))
+				})
+			})
+
+			context("when the module names an attribute", func() {
+				it.Before(func() {
+					env["BP_POETRY_RUN_WORKER_MODULE"] = "some_app.queue:app"
+				})
+
+				it("returns an error", func() {
+					_, _, err := resolver.ResolveWorkers(pyProject)
+					Expect(err).To(MatchError("invalid BP_POETRY_RUN_WORKER_MODULE value some_app.queue:app: RQ takes a settings module without ':', e.g. some_app.settings"))
+				})
+			})
+		})
+
+		context("when the project only depends on a task queue in another group", func() {
+			it.Before(func() {
+				pyProject.DependencyGroups = map[string][]poetryrun.Dependency{
+					"main": {{Name: "flask"}},
+					"dev":  {{Name: "celery"}},
+					"jobs": {{Name: "rq", Optional: true}},
+				}
+				appFS["tasks.py"] = &fstest.MapFile{}
+			})
+
+			it("returns no workers", func() {
+				workers, warnings, err := resolver.ResolveWorkers(pyProject)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(workers).To(BeEmpty())
+				Expect(warnings).To(BeEmpty())
+			})
+		})
+
+		context("when the module override is not a module path", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_WORKER_MODULE"] = "some_app/tasks.py"
+			})
+
+			it("returns an error", func() {
+				_, _, err := resolver.ResolveWorkers(pyProject)
+				Expect(err).To(MatchError("invalid BP_POETRY_RUN_WORKER_MODULE value some_app/tasks.py: must be a module path, e.g. some_app.tasks"))
+			})
+		})
+	})
+
+	context("Split", func() {
+		it("separates the fixed command from the default args", func() {
+			command, args := poetryrun.Target{Kind: poetryrun.CommandTarget, Argv: []string{"gunicorn", "some_app:app", "--workers", "2"}}.Split()
+			Expect(command).To(Equal([]string{"gunicorn"}))
+			Expect(args).To(Equal([]string{"some_app:app", "--workers", "2"}))
+		})
+
+		it("keeps 'python -m <module>' as the fixed command of a module target", func() {
+			command, args := poetryrun.Target{Kind: poetryrun.ModuleTarget, Argv: []string{"python", "-m", "some_app", "--port", "8080"}}.Split()
+			Expect(command).To(Equal([]string{"python", "-m", "some_app"}))
+			Expect(args).To(Equal([]string{"--port", "8080"}))
+		})
+
+		it("keeps 'python -c <code>' as the fixed command of a callable target", func() {
+			command, args := poetryrun.Target{Kind: poetryrun.CallableTarget, Argv: []string{"python", "-c", "import app; app.main()", "--port", "8080"}}.Split()
+			Expect(command).To(Equal([]string{"python", "-c", "import app; app.main()"}))
+			Expect(args).To(Equal([]string{"--port", "8080"}))
+		})
+
+		it("returns no default args when the target has none", func() {
+			command, args := poetryrun.Target{Kind: poetryrun.ScriptTarget, Argv: []string{"some-script"}}.Split()
+			Expect(command).To(Equal([]string{"some-script"}))
+			Expect(args).To(BeNil())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_POETRY_RUN_TARGET is empty", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_TARGET"] = " "
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(pyProject)
+				Expect(err).To(MatchError("BP_POETRY_RUN_TARGET must not be empty"))
+			})
+		})
+
+		context("when BP_POETRY_RUN_EXPAND_ENV is not a bool", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_EXPAND_ENV"] = "sometimes"
+				env["BP_POETRY_RUN_TARGET"] = "gunicorn some_app:app"
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(pyProject)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_POETRY_RUN_EXPAND_ENV value sometimes")))
+			})
+		})
+
+		context("when an expanded variable is undefined", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_EXPAND_ENV"] = "true"
+				env["BP_POETRY_RUN_TARGET"] = "gunicorn ${APP_MODULE}:app"
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(pyProject)
+				Expect(err).To(MatchError("failed to expand BP_POETRY_RUN_TARGET: undefined variable APP_MODULE: set it at build time or give a default with ${APP_MODULE:-default}"))
+			})
+		})
+
+		context("when a variable reference is not terminated", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_EXPAND_ENV"] = "true"
+				pyProject.PoetryRun.Target = "gunicorn ${APP_MODULE:app"
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(pyProject)
+				Expect(err).To(MatchError(`failed to expand [tool.paketo.poetry-run] target: unterminated variable reference "${APP_MODULE:app": add a closing '}' or escape '$' as '$$'`))
+			})
+		})
+
+		context("when a variable name is invalid", func() {
+			it.Before(func() {
+				env["BP_POETRY_RUN_EXPAND_ENV"] = "true"
+				env["BP_POETRY_RUN_TARGET"] = "gunicorn ${APP-MODULE}:app"
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(pyProject)
+				Expect(err).To(MatchError("failed to expand BP_POETRY_RUN_TARGET: invalid variable reference ${APP-MODULE}: variable names may only contain letters, numbers and '_'"))
+			})
+		})
+	})
+}
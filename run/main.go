@@ -12,15 +12,20 @@ import (
 func main() {
 	logger := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
 	pyProjectParser := poetryrun.NewPyProjectConfigParser()
+	lockFileParser := poetryrun.NewPoetryLockParser()
+	configFileParser := poetryrun.NewPoetryConfigParser()
 
 	reloader := watchexec.NewWatchexecReloader()
 
 	packit.Run(
-		poetryrun.Detect(pyProjectParser, reloader),
+		poetryrun.Detect(pyProjectParser, lockFileParser, logger, reloader, os.Environ),
 		poetryrun.Build(
 			pyProjectParser,
+			lockFileParser,
+			configFileParser,
 			logger,
 			reloader,
+			os.Environ,
 		),
 	)
 }
@@ -0,0 +1,48 @@
+package poetryrun
+
+const (
+	// CPythonVersionLabel is the image label that records the version of the
+	// CPython the venv was created from.
+	CPythonVersionLabel = "io.paketo.poetry-run.cpython-version"
+
+	// VenvPythonVersionLabel is the image label that records the Python
+	// version the venv was created with.
+	VenvPythonVersionLabel = "io.paketo.poetry-run.venv-python-version"
+)
+
+// buildRuntime returns the Python runtime the app is built with: the venv of
+// the project, and the version of the CPython it was created from, read from
+// its pyvenv.cfg. The build plan does not carry the CPython version, so the
+// venv is the only record of it. Fields that cannot be found are left empty.
+func (r Resolver) buildRuntime(workingDir, project string) ReportRuntime {
+	venv, err := FindVenv(r.lookupEnv, workingDir, project)
+	if err != nil {
+		return ReportRuntime{}
+	}
+
+	runtime := ReportRuntime{Venv: venv}
+	if config, err := ReadVenvConfig(venv); err == nil {
+		runtime.CPythonVersion = config.Version
+		runtime.VenvPythonVersion = config.Version
+	}
+
+	return runtime
+}
+
+// labels returns the image labels that record the runtime.
+func (r ReportRuntime) labels() map[string]string {
+	labels := map[string]string{}
+	if r.CPythonVersion != "" {
+		labels[CPythonVersionLabel] = r.CPythonVersion
+	}
+
+	if r.VenvPythonVersion != "" {
+		labels[VenvPythonVersionLabel] = r.VenvPythonVersion
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	return labels
+}
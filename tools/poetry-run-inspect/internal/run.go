@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/libreload-packit/watchexec"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	poetryrun "github.com/paketo-buildpacks/poetry-run"
+)
+
+// ErrDetectionFailed is returned when the buildpack would fail detection
+// for the inspected app.
+var ErrDetectionFailed = errors.New("detection failed")
+
+// Result is what the buildpack would do for the inspected app.
+type Result struct {
+	Detected bool              `json:"detected"`
+	Reason   string            `json:"reason,omitempty"`
+	Requires []Requirement     `json:"requires"`
+	Report   *poetryrun.Report `json:"report,omitempty"`
+}
+
+// Requirement is an entry of the build plan the buildpack would require.
+type Requirement struct {
+	Name   string `json:"name"`
+	Build  bool   `json:"build,omitempty"`
+	Launch bool   `json:"launch"`
+}
+
+type envFlag map[string]string
+
+func (f envFlag) String() string {
+	return ""
+}
+
+func (f envFlag) Set(value string) error {
+	name, value, found := strings.Cut(value, "=")
+	if !found || name == "" {
+		return fmt.Errorf("expected NAME=VALUE")
+	}
+
+	f[name] = value
+	return nil
+}
+
+// Run runs the detect and build phases of the buildpack against an app
+// directory, without a lifecycle or a container runtime, and prints the build
+// plan and processes they would produce. Flags are:
+//
+//	--dir <path>       the app directory (default: the working directory)
+//	--env NAME=VALUE   an environment variable, e.g. BP_POETRY_RUN_TARGET (repeatable)
+//	--format text|json the output format (default: text)
+//	--verbose          also write the buildpack log to logs
+//
+// The buildpack only sees the variables given with --env, not the environment
+// of the process, so that the result does not depend on the shell it runs in.
+//
+// Run returns ErrDetectionFailed, after printing the reason, when the
+// buildpack would fail detection.
+func Run(args []string, output, logs io.Writer) error {
+	flags := flag.NewFlagSet("poetry-run-inspect", flag.ContinueOnError)
+	flags.SetOutput(logs)
+
+	env := envFlag{}
+	dir := flags.String("dir", ".", "the app directory")
+	format := flags.String("format", "text", "the output format: text or json")
+	verbose := flags.Bool("verbose", false, "also print the buildpack log")
+	flags.Var(env, "env", "an environment variable as NAME=VALUE (repeatable)")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid format %q: must be text or json", *format)
+	}
+
+	workingDir, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+
+	logOutput := io.Discard
+	if *verbose {
+		logOutput = logs
+	}
+
+	result, err := inspect(workingDir, env.environ(), scribe.NewEmitter(logOutput).WithLevel("DEBUG"))
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		content, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(output, string(content))
+	} else {
+		printText(output, result)
+	}
+
+	if !result.Detected {
+		return ErrDetectionFailed
+	}
+
+	return nil
+}
+
+func inspect(workingDir string, environ []string, logger scribe.Emitter) (Result, error) {
+	lookupEnviron := func() []string { return environ }
+
+	cnbDir, err := os.MkdirTemp("", "poetry-run-inspect-cnb")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(cnbDir)
+
+	layersDir, err := os.MkdirTemp("", "poetry-run-inspect-layers")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(layersDir)
+
+	// Build copies its helper binaries from the buildpack into layers, so
+	// placeholders stand in for them.
+	err = os.MkdirAll(filepath.Join(cnbDir, "bin"), os.ModePerm)
+	if err != nil {
+		return Result{}, err
+	}
+
+	for _, helper := range []string{"health", "sync-dependencies"} {
+		err = os.WriteFile(filepath.Join(cnbDir, "bin", helper), nil, 0755)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	pyProjectParser := poetryrun.NewPyProjectConfigParser()
+	lockFileParser := poetryrun.NewPoetryLockParser()
+	reloader := watchexec.NewWatchexecReloader()
+
+	detectResult, err := poetryrun.Detect(pyProjectParser, lockFileParser, logger, reloader, lookupEnviron)(packit.DetectContext{
+		WorkingDir: workingDir,
+		CNBPath:    cnbDir,
+	})
+	if err != nil {
+		failure := packit.Fail
+		if errors.As(err, &failure) {
+			return Result{Reason: err.Error(), Requires: []Requirement{}}, nil
+		}
+
+		return Result{}, err
+	}
+
+	result := Result{Detected: true, Requires: []Requirement{}}
+
+	var entries []packit.BuildpackPlanEntry
+	for _, requirement := range detectResult.Plan.Requires {
+		metadata, _ := requirement.Metadata.(poetryrun.BuildPlanMetadata)
+		result.Requires = append(result.Requires, Requirement{Name: requirement.Name, Build: metadata.Build, Launch: metadata.Launch})
+		entries = append(entries, packit.BuildpackPlanEntry{Name: requirement.Name})
+	}
+
+	_, err = poetryrun.Build(pyProjectParser, lockFileParser, poetryrun.NewPoetryConfigParser(), logger, reloader, lookupEnviron)(packit.BuildContext{
+		WorkingDir: workingDir,
+		CNBPath:    cnbDir,
+		Layers:     packit.Layers{Path: layersDir},
+		Plan:       packit.BuildpackPlan{Entries: entries},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	var report poetryrun.Report
+	_, err = toml.DecodeFile(filepath.Join(layersDir, poetryrun.ReportLayerName, "report.toml"), &report)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read build report: %w", err)
+	}
+	result.Report = &report
+
+	return result, nil
+}
+
+func printText(output io.Writer, result Result) {
+	if !result.Detected {
+		fmt.Fprintln(output, "detect = fail")
+		fmt.Fprintf(output, "reason = %s\n", result.Reason)
+		return
+	}
+
+	fmt.Fprintln(output, "detect = pass")
+	for _, requirement := range result.Requires {
+		if requirement.Build {
+			fmt.Fprintf(output, "requires.%s.build = true\n", requirement.Name)
+		}
+		fmt.Fprintf(output, "requires.%s.launch = %t\n", requirement.Name, requirement.Launch)
+	}
+
+	for _, line := range result.Report.Summary() {
+		fmt.Fprintln(output, line)
+	}
+}
+
+// environ returns the given variables as "NAME=value" pairs.
+func (f envFlag) environ() []string {
+	var environ []string
+	for name, value := range f {
+		environ = append(environ, name+"="+value)
+	}
+
+	return environ
+}
@@ -0,0 +1,152 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/poetry-run/tools/poetry-run-inspect/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		output     *bytes.Buffer
+		logs       *bytes.Buffer
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.poetry]
+name = "some-app"
+
+[tool.poetry.scripts]
+some-script = "some_app.server:main"
+`), 0644)).To(Succeed())
+
+		output = bytes.NewBuffer(nil)
+		logs = bytes.NewBuffer(nil)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	it("prints the build plan and processes as text", func() {
+		err := internal.Run([]string{"--dir", workingDir}, output, logs)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(output.String()).To(Equal(`detect = pass
+requires.cpython.launch = true
+requires.poetry.launch = true
+requires.poetry-venv.build = true
+requires.poetry-venv.launch = true
+target.command = poetry run some-script
+target.kind = script
+target.source = single-script
+process.web = poetry run some-script
+process.web.default = true
+process.web.source = single-script
+reload.enabled = false
+`))
+		Expect(logs.String()).To(BeEmpty())
+	})
+
+	context("with --env", func() {
+		it("resolves the target with the given environment variables", func() {
+			err := internal.Run([]string{"--dir", workingDir, "--env", "BP_POETRY_RUN_TARGET=gunicorn some_app:app"}, output, logs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output.String()).To(ContainSubstring("target.command = poetry run gunicorn some_app:app\n"))
+			Expect(output.String()).To(ContainSubstring("target.source = environment\n"))
+
+			_, set := os.LookupEnv("BP_POETRY_RUN_TARGET")
+			Expect(set).To(BeFalse())
+		})
+
+		it("ignores the environment of the process", func() {
+			Expect(os.Setenv("BP_POETRY_RUN_TARGET", "gunicorn some_app:app")).To(Succeed())
+			defer os.Unsetenv("BP_POETRY_RUN_TARGET")
+
+			err := internal.Run([]string{"--dir", workingDir, "--env", "BP_POETRY_RUN_EXPAND_ENV=true"}, output, logs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output.String()).To(ContainSubstring("target.command = poetry run some-script\n"))
+			Expect(os.Getenv("BP_POETRY_RUN_TARGET")).To(Equal("gunicorn some_app:app"))
+		})
+	})
+
+	context("with --format json", func() {
+		it("prints the build plan and the build report as JSON", func() {
+			err := internal.Run([]string{"--dir", workingDir, "--format", "json"}, output, logs)
+			Expect(err).NotTo(HaveOccurred())
+
+			var result internal.Result
+			Expect(json.Unmarshal(output.Bytes(), &result)).To(Succeed())
+
+			Expect(result.Detected).To(BeTrue())
+			Expect(result.Requires).To(ContainElement(internal.Requirement{Name: "poetry-venv", Build: true, Launch: true}))
+			Expect(result.Report.Target.Command).To(Equal([]string{"poetry", "run", "some-script"}))
+			Expect(result.Report.Processes).To(HaveLen(1))
+			Expect(result.Report.Processes[0].Type).To(Equal("web"))
+		})
+	})
+
+	context("with --verbose", func() {
+		it("also prints the buildpack log", func() {
+			err := internal.Run([]string{"--dir", workingDir, "--verbose"}, output, logs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(logs.String()).To(ContainSubstring("Assigning launch processes:"))
+		})
+	})
+
+	context("when detection would fail", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.poetry.scripts]
+some-script = "some_app.server:main"
+other-script = "some_app.cli:main"
+`), 0644)).To(Succeed())
+		})
+
+		it("prints the reason and returns ErrDetectionFailed", func() {
+			err := internal.Run([]string{"--dir", workingDir}, output, logs)
+			Expect(err).To(MatchError(internal.ErrDetectionFailed))
+
+			Expect(output.String()).To(Equal(`detect = fail
+reason = pyproject.toml defines 2 scripts under [tool.poetry.scripts] (other-script, some-script). Keep exactly one script, or set BP_POETRY_RUN_TARGET to the one to run.
+`))
+		})
+	})
+
+	context("failure cases", func() {
+		it("returns an error for an invalid format", func() {
+			err := internal.Run([]string{"--format", "yaml"}, output, logs)
+			Expect(err).To(MatchError(`invalid format "yaml": must be text or json`))
+		})
+
+		it("returns an error for an invalid --env", func() {
+			err := internal.Run([]string{"--env", "BP_POETRY_RUN_TARGET"}, output, logs)
+			Expect(err).To(MatchError(ContainSubstring("expected NAME=VALUE")))
+		})
+
+		context("when the build would fail", func() {
+			it("returns the error", func() {
+				err := internal.Run([]string{"--dir", workingDir, "--env", "BP_POETRY_RUN_PROCESS_WEB=python"}, output, logs)
+				Expect(err).To(MatchError(`invalid process type "web": conflicts with a process assigned by this buildpack`))
+			})
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package poetryrun
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VenvConfig is the subset of a venv's pyvenv.cfg that this buildpack cares
+// about.
+type VenvConfig struct {
+	// Home is the directory of the Python executable the venv was created
+	// from.
+	Home string
+
+	// Version is the Python version the venv was created with, e.g. "3.11.9".
+	Version string
+}
+
+// FindVenv returns the venv of the named project for the app at workingDir,
+// reading the environment with lookupEnv: $VIRTUAL_ENV, .venv in the app
+// directory, or the venv under $POETRY_VIRTUALENVS_PATH that Poetry named
+// after the project, or the only one there. It returns an error when there is
+// none or it is ambiguous.
+func FindVenv(lookupEnv func(string) (string, bool), workingDir, project string) (string, error) {
+	if venv, _ := lookupEnv("VIRTUAL_ENV"); venv != "" {
+		if !isVenv(venv) {
+			return "", fmt.Errorf("VIRTUAL_ENV is set to %s, which is not a venv", venv)
+		}
+
+		return venv, nil
+	}
+
+	if venv := filepath.Join(workingDir, ".venv"); isVenv(venv) {
+		return venv, nil
+	}
+
+	path, _ := lookupEnv("POETRY_VIRTUALENVS_PATH")
+	if path == "" {
+		return "", errors.New("failed to find the venv: there is no .venv in the app and POETRY_VIRTUALENVS_PATH is not set")
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	prefix := normalizePackageName(project) + "-"
+
+	var venvs, matches []string
+	for _, entry := range entries {
+		venv := filepath.Join(path, entry.Name())
+		if !isVenv(venv) {
+			continue
+		}
+
+		venvs = append(venvs, venv)
+		if project != "" && strings.HasPrefix(entry.Name(), prefix) {
+			matches = append(matches, venv)
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(venvs) == 1:
+		return venvs[0], nil
+	case len(venvs) == 0:
+		return "", fmt.Errorf("failed to find the venv: there is no venv in %s", path)
+	default:
+		return "", fmt.Errorf("failed to find the venv: there are several venvs in %s: %s", path, strings.Join(venvs, ", "))
+	}
+}
+
+func isVenv(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "pyvenv.cfg"))
+	return err == nil && !info.IsDir()
+}
+
+// ReadVenvConfig parses the pyvenv.cfg of the given venv.
+func ReadVenvConfig(venv string) (VenvConfig, error) {
+	path := filepath.Join(venv, "pyvenv.cfg")
+	file, err := os.Open(path)
+	if err != nil {
+		return VenvConfig{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var config VenvConfig
+	var versionInfo string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "home":
+			config.Home = strings.TrimSpace(value)
+		case "version":
+			config.Version = strings.TrimSpace(value)
+		case "version_info":
+			versionInfo = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return VenvConfig{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// virtualenv, which Poetry uses, writes version_info, e.g.
+	// "3.11.9.final.0", while the venv module writes version.
+	if versionInfo != "" {
+		parts := strings.SplitN(versionInfo, ".", 4)
+		config.Version = strings.Join(parts[:min(len(parts), 3)], ".")
+	}
+
+	return config, nil
+}
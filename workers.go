@@ -0,0 +1,173 @@
+package poetryrun
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Worker is a non-default process that runs a task queue worker, inferred
+// from the dependencies of the project.
+type Worker struct {
+	// ProcessType is the type of the process, e.g. "worker" or "beat".
+	ProcessType string
+
+	Target Target
+}
+
+// workerFrameworks are the supported task queues, in order of precedence.
+// Each conventional app module is given by its file, relative to a package of
+// the project when prefixed with "<package>/", and the module it is run as.
+// The app module may name an attribute after ':' when attribute is true.
+var workerFrameworks = []struct {
+	name       string
+	dependency string
+	attribute  bool
+	modules    [][2]string
+	commands   func(module string) map[string][]string
+}{
+	{
+		name:       "Celery",
+		dependency: "celery",
+		attribute:  true,
+		modules: [][2]string{
+			{"<package>/celery.py", "<package>"},
+			{"celery_app.py", "celery_app"},
+			{"tasks.py", "tasks"},
+			{"worker.py", "worker"},
+		},
+		commands: func(module string) map[string][]string {
+			return map[string][]string{
+				"worker": {"celery", "-A", module, "worker", "--loglevel", "INFO"},
+				"beat":   {"celery", "-A", module, "beat", "--loglevel", "INFO"},
+			}
+		},
+	},
+	{
+		name:       "Dramatiq",
+		dependency: "dramatiq",
+		attribute:  true,
+		modules: [][2]string{
+			{"<package>/tasks.py", "<package>.tasks"},
+			{"<package>/actors.py", "<package>.actors"},
+			{"tasks.py", "tasks"},
+			{"actors.py", "actors"},
+			{"worker.py", "worker"},
+		},
+		commands: func(module string) map[string][]string {
+			return map[string][]string{
+				"worker": {"dramatiq", module},
+			}
+		},
+	},
+	{
+		name:       "RQ",
+		dependency: "rq",
+		commands: func(module string) map[string][]string {
+			if module == "" {
+				return map[string][]string{"worker": {"rq", "worker"}}
+			}
+
+			return map[string][]string{"worker": {"rq", "worker", "-c", module}}
+		},
+	},
+}
+
+var workerModulePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*(:[A-Za-z_][A-Za-z0-9_.]*)?$`)
+
+// ResolveWorkers returns the worker processes of the first task queue among
+// the main dependencies of the project: a 'worker' process, and a 'beat'
+// process for Celery, sorted by process type. The app module is taken from
+// BP_POETRY_RUN_WORKER_MODULE or [tool.paketo.poetry-run] worker-module, or
+// located by its conventional file names. RQ runs without an app module; one
+// that is given is passed as its settings module, so it must not name an
+// attribute.
+//
+// When the project depends on a task queue but no app module can be located,
+// ResolveWorkers returns no workers and a warning explaining why.
+func (r Resolver) ResolveWorkers(pyProject PyProject) ([]Worker, []string, error) {
+	module, origin := pyProject.PoetryRun.WorkerModule, "[tool.paketo.poetry-run] worker-module"
+	if value, ok := r.lookupEnv("BP_POETRY_RUN_WORKER_MODULE"); ok {
+		module, origin = value, "BP_POETRY_RUN_WORKER_MODULE"
+	}
+
+	if module != "" && !workerModulePattern.MatchString(module) {
+		return nil, nil, fmt.Errorf("invalid %s value %s: must be a module path, e.g. some_app.tasks", origin, module)
+	}
+
+	for _, framework := range workerFrameworks {
+		if !pyProject.HasMainDependency(framework.dependency) {
+			continue
+		}
+
+		if !framework.attribute && strings.Contains(module, ":") {
+			return nil, nil, fmt.Errorf("invalid %s value %s: %s takes a settings module without ':', e.g. some_app.settings", origin, module, framework.name)
+		}
+
+		explanation := fmt.Sprintf("Found %s in pyproject.toml and %s=%s", framework.dependency, origin, module)
+		if module == "" && len(framework.modules) > 0 {
+			var file string
+			file, module = r.locateWorkerModule(pyProject, framework.modules)
+			if module == "" {
+				return nil, []string{fmt.Sprintf("found %s in pyproject.toml but no app module to run a worker for: set BP_POETRY_RUN_WORKER_MODULE", framework.dependency)}, nil
+			}
+
+			explanation = fmt.Sprintf("Found %s in pyproject.toml and %s app module %s", framework.dependency, framework.name, file)
+		} else if module == "" {
+			explanation = fmt.Sprintf("Found %s in pyproject.toml", framework.dependency)
+		}
+
+		commands := framework.commands(module)
+
+		var workers []Worker
+		for _, processType := range []string{"beat", "worker"} {
+			argv, ok := commands[processType]
+			if !ok {
+				continue
+			}
+
+			workers = append(workers, Worker{
+				ProcessType: processType,
+				Target: Target{
+					Kind:        CommandTarget,
+					Argv:        argv,
+					Source:      WorkerSource,
+					Explanation: explanation,
+				},
+			})
+		}
+
+		return workers, nil, nil
+	}
+
+	return nil, nil, nil
+}
+
+// locateWorkerModule returns the first of the given conventional app modules
+// that exists in the app, with its file.
+func (r Resolver) locateWorkerModule(pyProject PyProject, modules [][2]string) (string, string) {
+	packages := projectPackages(pyProject)
+
+	for _, candidate := range modules {
+		file, module := candidate[0], candidate[1]
+
+		relative, inPackage := strings.CutPrefix(file, "<package>/")
+		if !inPackage {
+			if _, err := fs.Stat(r.appFS, file); err == nil {
+				return file, module
+			}
+			continue
+		}
+
+		for _, p := range packages {
+			file := path.Join(p.dir, relative)
+			if _, err := fs.Stat(r.appFS, file); err == nil {
+				return file, strings.ReplaceAll(module, "<package>", p.module)
+			}
+		}
+	}
+
+	return "", ""
+}